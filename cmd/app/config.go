@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"image-sharing/internal/configs"
+)
+
+const configUsage = `usage: app [flags] config print
+
+prints the effective configuration with secrets redacted`
+
+func runConfig(args []string, config configs.Config) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("%s", configUsage)
+	}
+	if err := config.Print(os.Stdout); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
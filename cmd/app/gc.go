@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"image-sharing/internal/configs"
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/storage"
+)
+
+const gcUsage = `usage: app [flags] gc [-delete] [-min-age duration]
+
+lists files in the images directory that no post, blob or upload refers to
+and removes them with -delete`
+
+func runGC(args []string, dbConnection *sql.DB, config configs.Config) error {
+	ctx := context.Background()
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), gcUsage) }
+	remove := flags.Bool("delete", false, "remove the orphan files instead of only listing them")
+	minAge := flags.Duration("min-age", time.Hour, "ignore files modified more recently than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("%s", gcUsage)
+	}
+
+	// Take the cutoff before reading the database, so a file written after
+	// its rows were listed is never mistaken for an orphan.
+	cutoff := time.Now().Add(-*minAge)
+	queries := db.New(dbConnection)
+	paths, err := queries.ListStoragePaths(ctx)
+	if err != nil {
+		return err
+	}
+	uploads, err := queries.ListUploadIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	store := storage.NewFilesystem(config.ImagesDirectory)
+	orphans, err := store.Orphans(paths, uploads, cutoff)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, orphan := range orphans {
+		if *remove {
+			if err := store.Remove(orphan.Path); err != nil {
+				return err
+			}
+			fmt.Printf("removed %s (%d bytes)\n", orphan.Path, orphan.Size)
+		} else {
+			fmt.Printf("%s\t%d\t%s\n", orphan.Path, orphan.Size, orphan.ModTime.Format(time.RFC3339))
+		}
+		total += orphan.Size
+	}
+	if *remove {
+		fmt.Printf("removed %d orphan files, %d bytes\n", len(orphans), total)
+	} else {
+		fmt.Printf("found %d orphan files, %d bytes\n", len(orphans), total)
+	}
+	return nil
+}
@@ -1,28 +1,68 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"image-sharing/internal/admin"
 	"image-sharing/internal/configs"
+	"image-sharing/internal/db/migrations"
+	"image-sharing/internal/health"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/metrics"
+	"image-sharing/internal/migrate"
 	"image-sharing/internal/routes"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
+	"image-sharing/internal/worker"
+	"image-sharing/pkg/token"
 )
 
 func main() {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	slog.SetDefault(logger)
 	err := godotenv.Load(".env")
+	envLoaded := err == nil
+
+	config, args, err := configs.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, config.LogFormat, config.LogLevel))
+	if !envLoaded {
 		slog.Debug(".env file not found")
 	}
 
-	config := configs.NewConfig()
+	if len(args) > 0 {
+		switch {
+		case args[0] == "config":
+			exitOnError(runConfig(args[1:], config))
+			return
+		case len(args) > 1 && args[0] == "migrate" && args[1] == "create":
+			exitOnError(createMigration(args[2:]))
+			return
+		case args[0] != "migrate" && args[0] != "gc":
+			exitOnError(fmt.Errorf("unknown command %q", args[0]))
+		}
+	}
+
+	if err = config.Validate(); err != nil {
+		exitOnError(fmt.Errorf("invalid configuration:\n%w", err))
+	}
 
 	if err = os.MkdirAll(config.ImagesDirectory, 0755); err != nil {
 		panic(err)
@@ -33,27 +73,161 @@ func main() {
 		panic(err)
 	}
 	defer db.Close()
+	db.SetMaxOpenConns(config.DBMaxOpenConns)
+	db.SetMaxIdleConns(config.DBMaxIdleConns)
+	db.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.DBConnMaxIdleTime)
 	if err = db.Ping(); err != nil {
 		panic(err)
 	}
 
-	SchemaPath := config.SchemaPath
-	schema, err := os.ReadFile(SchemaPath)
+	if len(args) > 0 && args[0] == "migrate" {
+		if err = runMigrate(args[1:], db); err != nil {
+			db.Close()
+			exitOnError(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "gc" {
+		if err = runGC(args[1:], db, config); err != nil {
+			db.Close()
+			exitOnError(err)
+		}
+		return
+	}
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		panic(err)
+	}
+	if config.MigrateOnStart {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		for _, migration := range applied {
+			slog.Info(fmt.Sprintf("Applied migration %04d_%s", migration.Version, migration.Name))
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     config.TracingExporter,
+		OTLPEndpoint: config.OTLPEndpoint,
+		OTLPInsecure: config.OTLPInsecure,
+		SampleRatio:  config.TracingSampleRatio,
+	})
+	if err != nil {
+		slog.Error("Failed to set up tracing", "exporter", config.TracingExporter, "error", err)
+		db.Close()
+		os.Exit(1)
+	}
+
+	tokenMaker, err := token.NewMaker(config.JWTAlgorithm, config.SecretKey, config.JWTKeysDir, config.JWTSigningKeyID)
 	if err != nil {
 		panic(err)
 	}
-	_, err = db.Exec(string(schema))
+
+	healthChecker := health.NewChecker(config.HealthTimeout)
+	healthChecker.Add("database", health.DatabaseCheck(db))
+	healthChecker.Add("migrations", health.MigrationsCheck(migrator))
+	healthChecker.Add("storage", health.StorageCheck(config.ImagesDirectory))
+
+	registry := metrics.NewRegistry()
+	router, err := routes.SetupRouter(db, config, tokenMaker, registry)
 	if err != nil {
 		panic(err)
 	}
 
-	router := routes.SetupRouter(db, config)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	router.Mount("/debug/pprof", http.DefaultServeMux)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	scheduler := worker.NewScheduler(worker.NewElector(db, worker.LeaderLockKey), metrics.NewJobMetrics(registry))
+	scheduler.Add(worker.NewSessionCleaner(db, config.SessionRevokedRetention, int32(config.SessionCleanupBatch)), config.SessionCleanupInterval)
+	scheduler.Add(worker.NewUploadCleaner(db, storage.NewFilesystem(config.ImagesDirectory)), config.UploadCleanupInterval)
+	scheduler.Add(worker.NewStorageCleaner(db, storage.NewFilesystem(config.ImagesDirectory)), config.StorageCleanupInterval)
+	scheduler.Add(worker.NewTrashCleaner(db, config.TrashRetention), config.TrashCleanupInterval)
+	if config.RateLimitStore == "postgres" {
+		scheduler.Add(worker.NewRateLimitCleaner(db), config.RateLimitCleanupInterval)
+	}
+	go func() {
+		scheduler.Run(workerCtx)
+		close(workersDone)
+	}()
 
-	slog.Info(fmt.Sprintf("Server started on: http://%s", config.Address))
-	err = http.ListenAndServe(config.Address, router)
+	adminServer, err := admin.NewServer(config, metrics.Handler(registry), healthChecker, migrator).HTTPServer()
 	if err != nil {
 		panic(err)
 	}
+
+	server := &http.Server{
+		Addr:              config.Address,
+		Handler:           router,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info(fmt.Sprintf("Server started on: http://%s", config.Address))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+	go func() {
+		var err error
+		if adminServer.TLSConfig != nil {
+			slog.Info(fmt.Sprintf("Admin server started on: https://%s", config.AdminAddress))
+			err = adminServer.ListenAndServeTLS("", "")
+		} else {
+			slog.Info(fmt.Sprintf("Admin server started on: http://%s", config.AdminAddress))
+			err = adminServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err = <-serverErr:
+		panic(err)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("Shutting down, draining in-flight requests")
+	healthChecker.SetShuttingDown()
+	time.Sleep(config.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Graceful shutdown failed, closing remaining connections", "error", err)
+		server.Close()
+	}
+
+	// The admin server goes last so probes and scrapes keep working while the API drains.
+	if err = adminServer.Shutdown(shutdownCtx); err != nil {
+		adminServer.Close()
+	}
+
+	stopWorkers()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Background workers did not stop before the shutdown deadline")
+	}
+	if err = shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
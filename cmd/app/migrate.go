@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"image-sharing/internal/db/migrations"
+	"image-sharing/internal/migrate"
+)
+
+const migrateUsage = `usage: app migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [steps]  roll back the last applied migrations (default 1)
+  status        list migrations and when they were applied
+  create <name> add an empty migration to internal/db/migrations`
+
+const migrationsDir = "internal/db/migrations"
+
+func runMigrate(args []string, db *sql.DB) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// createMigration doesn't need a database connection.
+func createMigration(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+	upPath, downPath, err := migrate.Create(migrationsDir, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s\ncreated %s\n", upPath, downPath)
+	return nil
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"image-sharing/internal/configs"
+	"image-sharing/internal/health"
+	"image-sharing/internal/logging"
+	midle "image-sharing/internal/middleware"
+	"image-sharing/internal/migrate"
+)
+
+const realm = "image-sharing admin"
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Server holds the internal endpoints that must not be reachable through the
+// public API: metrics, health probes, pprof and operational tools.
+type Server struct {
+	config        configs.Config
+	metrics       http.Handler
+	healthChecker *health.Checker
+	migrator      *migrate.Migrator
+}
+
+func NewServer(config configs.Config, metrics http.Handler, healthChecker *health.Checker, migrator *migrate.Migrator) *Server {
+	return &Server{config: config, metrics: metrics, healthChecker: healthChecker, migrator: migrator}
+}
+
+func (s *Server) Router() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(midle.RequestID)
+	router.Use(midle.Logger(slog.Default()))
+	router.Use(middleware.Recoverer)
+
+	// Probes stay unauthenticated so the orchestrator can reach them.
+	router.Get("/healthz", s.healthChecker.Liveness)
+	router.Get("/readyz", s.healthChecker.Readiness)
+
+	router.Group(func(r chi.Router) {
+		if s.config.AdminUsername != "" {
+			r.Use(middleware.BasicAuth(realm, map[string]string{s.config.AdminUsername: s.config.AdminPassword}))
+		}
+		r.Get("/metrics", s.metrics.ServeHTTP)
+		r.Get("/config", s.Config)
+		r.Get("/migrations", s.Migrations)
+
+		r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		r.HandleFunc("/debug/pprof/*", pprof.Index)
+	})
+	return router
+}
+
+// HTTPServer builds the admin listener, with TLS when a certificate is
+// configured and client certificate verification when a client CA is.
+func (s *Server) HTTPServer() (*http.Server, error) {
+	server := &http.Server{
+		Addr:              s.config.AdminAddress,
+		Handler:           s.Router(),
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+	if s.config.AdminTLSCert == "" {
+		return server, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(s.config.AdminTLSCert, s.config.AdminTLSKey)
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if s.config.AdminClientCA != "" {
+		pem, err := os.ReadFile(s.config.AdminClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("admin_client_ca: no certificates found")
+		}
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return server, nil
+}
+
+// Config prints the effective configuration with secrets redacted.
+func (s *Server) Config(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	if err := s.config.Print(w); err != nil {
+		logging.FromContext(r.Context()).Error("failed to print config", "error", err)
+	}
+}
+
+func (s *Server) Migrations(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.migrator.Status(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to load migration status", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	response := make([]MigrationStatus, len(statuses))
+	for i, status := range statuses {
+		response[i] = MigrationStatus{Version: status.Version, Name: status.Name, AppliedAt: status.AppliedAt}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
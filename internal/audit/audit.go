@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/middleware"
+)
+
+const maxColumnLength = 255
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+const (
+	ActionLogin          = "auth.login"
+	ActionLogout         = "auth.logout"
+	ActionTokenRenew     = "auth.token_renew"
+	ActionSessionsRevoke = "auth.sessions_revoke"
+	ActionLockout        = "auth.lockout"
+	ActionUnlock         = "auth.unlock"
+	ActionPasswordChange = "user.password_change"
+	ActionUserDelete     = "user.delete"
+	ActionAPITokenCreate = "api_token.create"
+	ActionAPITokenRevoke = "api_token.revoke"
+	ActionRoleCreate     = "role.create"
+	ActionRoleDelete     = "role.delete"
+	ActionRoleAssign     = "role.assign"
+	ActionRoleRemove     = "role.remove"
+	ActionPostDelete     = "post.delete"
+	ActionPostRestore    = "post.restore"
+	ActionPostPurge      = "post.purge"
+	ActionQuotaSet       = "quota.set"
+	ActionQuotaReset     = "quota.reset"
+)
+
+const (
+	TargetUser     = "user"
+	TargetSession  = "session"
+	TargetAPIToken = "api_token"
+	TargetRole     = "role"
+	TargetPost     = "post"
+	TargetLockout  = "lockout"
+)
+
+type Event struct {
+	// ActorID and ActorLogin are taken from the request claims when left empty.
+	ActorID    int32
+	ActorLogin string
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    Outcome
+	Details    map[string]any
+}
+
+// Service appends security relevant events to the audit_events table.
+type Service struct {
+	queries *db.Queries
+}
+
+func NewService(queries *db.Queries) *Service {
+	return &Service{queries: queries}
+}
+
+// Record never fails the request it is called from, a lost event is logged instead.
+func (s *Service) Record(r *http.Request, event Event) {
+	if claims, ok := r.Context().Value(middleware.AuthKey{}).(*middleware.AccessClaims); ok && event.ActorID == 0 {
+		event.ActorID = claims.ID
+		event.ActorLogin = claims.Login
+	}
+
+	logger := logging.FromContext(r.Context())
+	if requestID := middleware.GetRequestID(r.Context()); requestID != "" {
+		if event.Details == nil {
+			event.Details = map[string]any{}
+		}
+		event.Details["request_id"] = requestID
+	}
+
+	details := json.RawMessage("{}")
+	if len(event.Details) > 0 {
+		encoded, err := json.Marshal(event.Details)
+		if err != nil {
+			logger.Error("failed to encode audit details", "action", event.Action, "error", err)
+		} else {
+			details = encoded
+		}
+	}
+
+	// The event has to be written even if the client already went away.
+	ctx := context.WithoutCancel(r.Context())
+	err := s.queries.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		ActorID:    sql.NullInt32{Int32: event.ActorID, Valid: event.ActorID != 0},
+		ActorLogin: NullString(truncate(event.ActorLogin)),
+		Action:     event.Action,
+		TargetType: NullString(event.TargetType),
+		TargetID:   NullString(truncate(event.TargetID)),
+		Ip:         NullString(middleware.ClientIP(r)),
+		UserAgent:  NullString(r.UserAgent()),
+		Outcome:    string(event.Outcome),
+		Details:    details,
+	})
+	if err != nil {
+		logger.Error("failed to record audit event", "action", event.Action, "outcome", event.Outcome, "error", err)
+	}
+}
+
+func (s *Service) List(ctx context.Context, filter db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	return s.queries.ListAuditEvents(ctx, filter)
+}
+
+// truncate fits client supplied values, like unknown logins, into their columns.
+func truncate(value string) string {
+	if len(value) > maxColumnLength {
+		return strings.ToValidUTF8(value[:maxColumnLength], "")
+	}
+	return value
+}
+
+// NullString stores empty strings as NULL, audit filters treat NULL as any.
+func NullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
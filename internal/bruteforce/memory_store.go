@@ -0,0 +1,96 @@
+package bruteforce
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const memoryStoreSweepSize = 10000
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	// maxAge is the longest window seen, used to forget stale entries.
+	maxAge time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		return *entry, nil
+	}
+	return Entry{Key: key}, nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if window > s.maxAge {
+		s.maxAge = window
+	}
+	if len(s.entries) >= memoryStoreSweepSize {
+		s.sweep(now)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &Entry{Key: key}
+		s.entries[key] = entry
+	}
+	if entry.LastFailure.Before(now.Add(-window)) {
+		entry.Failures = 0
+	}
+	if entry.Locked && !entry.BlockedUntil.After(now) {
+		entry.Locked = false
+	}
+	entry.Failures++
+	entry.LastFailure = now
+	return *entry, nil
+}
+
+func (s *MemoryStore) Block(ctx context.Context, key string, until time.Time, locked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		entry.BlockedUntil = until
+		entry.Locked = locked
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok, nil
+}
+
+func (s *MemoryStore) ListLocked(ctx context.Context, now time.Time) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var locked []Entry
+	for _, entry := range s.entries {
+		if entry.Locked && entry.BlockedUntil.After(now) {
+			locked = append(locked, *entry)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].BlockedUntil.After(locked[j].BlockedUntil) })
+	return locked, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if entry.BlockedUntil.Before(now) && entry.LastFailure.Before(now.Add(-s.maxAge)) {
+			delete(s.entries, key)
+		}
+	}
+}
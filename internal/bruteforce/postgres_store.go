@@ -0,0 +1,76 @@
+package bruteforce
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+)
+
+// PostgresStore shares attempt counters between replicas.
+type PostgresStore struct {
+	queries *db.Queries
+}
+
+func NewPostgresStore(queries *db.Queries) *PostgresStore {
+	return &PostgresStore{queries: queries}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Entry, error) {
+	attempt, err := s.queries.GetLoginAttempt(ctx, key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Entry{Key: key}, nil
+		}
+		return Entry{}, err
+	}
+	return attemptToEntry(attempt), nil
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	attempt, err := s.queries.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         key,
+		Now:         now,
+		WindowStart: now.Add(-window),
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	return attemptToEntry(attempt), nil
+}
+
+func (s *PostgresStore) Block(ctx context.Context, key string, until time.Time, locked bool) error {
+	return s.queries.BlockLoginAttempt(ctx, db.BlockLoginAttemptParams{
+		Key:          key,
+		BlockedUntil: sql.NullTime{Time: until, Valid: true},
+		Locked:       locked,
+	})
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) (bool, error) {
+	rows, err := s.queries.DeleteLoginAttempt(ctx, key)
+	return rows > 0, err
+}
+
+func (s *PostgresStore) ListLocked(ctx context.Context, now time.Time) ([]Entry, error) {
+	attempts, err := s.queries.ListLockedLoginAttempts(ctx, sql.NullTime{Time: now, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(attempts))
+	for i, attempt := range attempts {
+		entries[i] = attemptToEntry(attempt)
+	}
+	return entries, nil
+}
+
+func attemptToEntry(attempt db.LoginAttempt) Entry {
+	return Entry{
+		Key:          attempt.Key,
+		Failures:     int(attempt.Failures),
+		LastFailure:  attempt.LastFailureAt,
+		BlockedUntil: attempt.BlockedUntil.Time,
+		Locked:       attempt.Locked,
+	}
+}
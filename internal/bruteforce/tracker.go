@@ -0,0 +1,165 @@
+package bruteforce
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindLogin    Kind = "login"
+	KindIP       Kind = "ip"
+	KindRegister Kind = "register"
+)
+
+// Policy describes how many attempts are allowed before a key is slowed down and then locked out.
+type Policy struct {
+	// FreeAttempts are allowed without any delay.
+	FreeAttempts int
+	// BaseDelay is doubled for every attempt after FreeAttempts, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter attempts the key is locked for LockoutDuration.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window after the last attempt the counter starts over.
+	Window time.Duration
+}
+
+var DefaultPolicies = map[Kind]Policy{
+	KindLogin: {
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	},
+	KindIP: {
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    50,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	},
+	KindRegister: {
+		FreeAttempts:    5,
+		BaseDelay:       10 * time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    20,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	},
+}
+
+type Entry struct {
+	Key          string
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+	Locked       bool
+}
+
+// Store keeps attempt counters. RecordFailure must increment atomically so replicas sharing a store agree.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, error)
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error)
+	Block(ctx context.Context, key string, until time.Time, locked bool) error
+	Reset(ctx context.Context, key string) (bool, error)
+	ListLocked(ctx context.Context, now time.Time) ([]Entry, error)
+}
+
+// BlockedError is returned while a key has to wait before the next attempt.
+type BlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+	// NewLockout is set on the attempt that caused the lockout, so it can be audited once.
+	NewLockout bool
+}
+
+func (e *BlockedError) Error() string {
+	return "too many attempts, try again later"
+}
+
+type Tracker struct {
+	store    Store
+	policies map[Kind]Policy
+	now      func() time.Time
+}
+
+func NewTracker(store Store, policies map[Kind]Policy) *Tracker {
+	return &Tracker{store: store, policies: policies, now: time.Now}
+}
+
+// Check returns a *BlockedError if the key is delayed or locked out.
+func (t *Tracker) Check(ctx context.Context, kind Kind, value string) error {
+	entry, err := t.store.Get(ctx, Key(kind, value))
+	if err != nil {
+		return err
+	}
+	if wait := entry.BlockedUntil.Sub(t.now()); wait > 0 {
+		return &BlockedError{RetryAfter: wait, Locked: entry.Locked}
+	}
+	return nil
+}
+
+// Record counts an attempt against the key and applies the delay or lockout it has earned.
+// It returns a *BlockedError when this attempt locked the key out.
+func (t *Tracker) Record(ctx context.Context, kind Kind, value string) error {
+	policy, ok := t.policies[kind]
+	if !ok {
+		return fmt.Errorf("no policy for %q", kind)
+	}
+
+	now := t.now()
+	key := Key(kind, value)
+	entry, err := t.store.RecordFailure(ctx, key, now, policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if entry.Failures >= policy.LockoutAfter {
+		until := now.Add(policy.LockoutDuration)
+		if err = t.store.Block(ctx, key, until, true); err != nil {
+			return err
+		}
+		return &BlockedError{RetryAfter: policy.LockoutDuration, Locked: true, NewLockout: !entry.Locked}
+	}
+
+	if entry.Failures > policy.FreeAttempts {
+		delay := policy.BaseDelay << (entry.Failures - policy.FreeAttempts - 1)
+		if delay <= 0 || delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+		return t.store.Block(ctx, key, now.Add(delay), false)
+	}
+	return nil
+}
+
+func (t *Tracker) Reset(ctx context.Context, kind Kind, value string) error {
+	_, err := t.store.Reset(ctx, Key(kind, value))
+	return err
+}
+
+// Unlock lifts a lockout by its full key and reports whether there was anything to lift.
+func (t *Tracker) Unlock(ctx context.Context, key string) (bool, error) {
+	return t.store.Reset(ctx, key)
+}
+
+func (t *Tracker) ListLocked(ctx context.Context) ([]Entry, error) {
+	return t.store.ListLocked(ctx, t.now())
+}
+
+// maxValueLength keeps keys built from user input within the login_attempts.key column.
+const maxValueLength = 255
+
+func Key(kind Kind, value string) string {
+	if len(value) > maxValueLength {
+		value = strings.ToValidUTF8(value[:maxValueLength], "")
+	}
+	return string(kind) + ":" + strings.ToLower(value)
+}
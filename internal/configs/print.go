@@ -0,0 +1,69 @@
+package configs
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	for _, s := range c.settings() {
+		value := s.value.String()
+		if s.secret && value != "" {
+			value = redact(value)
+		}
+		if _, ok := s.value.(listSetting); ok {
+			if _, err := fmt.Fprintf(w, "%s: [%s]\n", s.key, quoteList(value)); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := s.value.(stringSetting); ok {
+			value = strconv.Quote(value)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", s.key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redact keeps the non-secret parts of URLs so the target database is still
+// visible. Passwords are masked in the user info and the query, anything that
+// is not a URL, such as a key/value DSN, is redacted as a whole.
+func redact(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return redacted
+	}
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return redacted
+	}
+	for key := range query {
+		if strings.Contains(strings.ToLower(key), "password") {
+			// Masked like the user info password of url.URL.Redacted.
+			query[key] = []string{"xxxxx"}
+		}
+	}
+	if len(query) > 0 {
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.Redacted()
+}
+
+func quoteList(value string) string {
+	if value == "" {
+		return ""
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strconv.Quote(item)
+	}
+	return strings.Join(items, ", ")
+}
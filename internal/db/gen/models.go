@@ -6,12 +6,91 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+type ApiToken struct {
+	ID         int32
+	UserID     int32
+	Name       string
+	Prefix     string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type AuditEvent struct {
+	ID         int64
+	CreatedAt  time.Time
+	ActorID    sql.NullInt32
+	ActorLogin sql.NullString
+	Action     string
+	TargetType sql.NullString
+	TargetID   sql.NullString
+	Ip         sql.NullString
+	UserAgent  sql.NullString
+	Outcome    string
+	Details    json.RawMessage
+}
+
+type Blob struct {
+	Sha256      string
+	Path        string
+	SizeBytes   int64
+	ContentType string
+	RefCount    int32
+	CreatedAt   time.Time
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	BlockedUntil  sql.NullTime
+	Locked        bool
+}
+
+type Permission struct {
+	Name        string
+	Description sql.NullString
+}
+
 type Post struct {
-	ID        int32
-	UserID    int32
-	ImagePath string
+	ID          int32
+	UserID      int32
+	ImagePath   string
+	ContentType string
+	SizeBytes   int64
+	Sha256      string
+	DeletedAt   sql.NullTime
+	CreatedAt   time.Time
+}
+
+type PostMetadatum struct {
+	PostID    int32
+	Exif      []byte
+	CreatedAt time.Time
+}
+
+type RateLimit struct {
+	Key string
+	Tat time.Time
+}
+
+type Role struct {
+	ID          int32
+	Name        string
+	Description sql.NullString
+	IsBuiltin   bool
+}
+
+type RolePermission struct {
+	RoleID     int32
+	Permission string
 }
 
 type Session struct {
@@ -22,6 +101,28 @@ type Session struct {
 	IsRevoked    bool
 	CreatedAt    sql.NullTime
 	ExpiresAt    sql.NullTime
+	RevokedAt    sql.NullTime
+}
+
+type StorageDeletion struct {
+	ID            int64
+	Path          string
+	Sha256        string
+	CreatedAt     time.Time
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+}
+
+type Upload struct {
+	ID           string
+	UserID       int32
+	Length       int64
+	UploadOffset int64
+	Metadata     string
+	PostID       sql.NullInt32
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
 }
 
 type User struct {
@@ -30,6 +131,20 @@ type User struct {
 	Description sql.NullString
 }
 
+type UserQuota struct {
+	UserID           int32
+	MaxBytes         sql.NullInt64
+	MaxPosts         sql.NullInt64
+	MaxFileSize      sql.NullInt64
+	MaxUploadsPerDay sql.NullInt64
+	UpdatedAt        time.Time
+}
+
+type UserRole struct {
+	UserID int32
+	RoleID int32
+}
+
 type UsersAuth struct {
 	UserID       int32
 	Login        string
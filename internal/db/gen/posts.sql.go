@@ -7,10 +7,12 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const countPosts = `-- name: CountPosts :one
-SELECT count(*) FROM posts
+SELECT count(*) FROM posts WHERE deleted_at IS NULL
 `
 
 func (q *Queries) CountPosts(ctx context.Context) (int64, error) {
@@ -21,42 +23,76 @@ func (q *Queries) CountPosts(ctx context.Context) (int64, error) {
 }
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (user_id, image_path)
-VALUES ($1, $2) RETURNING id, user_id, image_path
+INSERT INTO posts (user_id, image_path, content_type, size_bytes, sha256)
+VALUES ($1, $2, $3, $4, $5) RETURNING id, user_id, image_path, content_type, size_bytes, sha256, deleted_at, created_at
 `
 
 type CreatePostParams struct {
-	UserID    int32
-	ImagePath string
+	UserID      int32
+	ImagePath   string
+	ContentType string
+	SizeBytes   int64
+	Sha256      string
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost, arg.UserID, arg.ImagePath)
+	row := q.db.QueryRowContext(ctx, createPost,
+		arg.UserID,
+		arg.ImagePath,
+		arg.ContentType,
+		arg.SizeBytes,
+		arg.Sha256,
+	)
 	var i Post
-	err := row.Scan(&i.ID, &i.UserID, &i.ImagePath)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ImagePath,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.DeletedAt,
+		&i.CreatedAt,
+	)
 	return i, err
 }
 
-const deletPost = `-- name: DeletPost :exec
-DELETE FROM posts WHERE id = $1
+const deletePost = `-- name: DeletePost :one
+DELETE FROM posts WHERE id = $1 RETURNING id, user_id, image_path, content_type, size_bytes, sha256, deleted_at, created_at
 `
 
-func (q *Queries) DeletPost(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deletPost, id)
-	return err
+func (q *Queries) DeletePost(ctx context.Context, id int32) (Post, error) {
+	row := q.db.QueryRowContext(ctx, deletePost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ImagePath,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.DeletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
 }
 
 const getPost = `-- name: GetPost :one
-SELECT posts.id, posts.user_id, posts.image_path, users.name as user_name FROM posts 
+SELECT posts.id, posts.user_id, posts.image_path, posts.content_type, posts.size_bytes, posts.sha256, posts.deleted_at, posts.created_at, users.name as user_name FROM posts 
 INNER JOIN users ON posts.user_id = users.id
-WHERE posts.id = $1
+WHERE posts.id = $1 AND posts.deleted_at IS NULL
 `
 
 type GetPostRow struct {
-	ID        int32
-	UserID    int32
-	ImagePath string
-	UserName  string
+	ID          int32
+	UserID      int32
+	ImagePath   string
+	ContentType string
+	SizeBytes   int64
+	Sha256      string
+	DeletedAt   sql.NullTime
+	CreatedAt   time.Time
+	UserName    string
 }
 
 func (q *Queries) GetPost(ctx context.Context, id int32) (GetPostRow, error) {
@@ -66,13 +102,18 @@ func (q *Queries) GetPost(ctx context.Context, id int32) (GetPostRow, error) {
 		&i.ID,
 		&i.UserID,
 		&i.ImagePath,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.DeletedAt,
+		&i.CreatedAt,
 		&i.UserName,
 	)
 	return i, err
 }
 
 const getPostUserID = `-- name: GetPostUserID :one
-SELECT user_id FROM posts WHERE posts.id = $1
+SELECT user_id FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NULL
 `
 
 func (q *Queries) GetPostUserID(ctx context.Context, id int32) (int32, error) {
@@ -82,9 +123,48 @@ func (q *Queries) GetPostUserID(ctx context.Context, id int32) (int32, error) {
 	return user_id, err
 }
 
+const getTrashedPostUserID = `-- name: GetTrashedPostUserID :one
+SELECT user_id FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetTrashedPostUserID(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getTrashedPostUserID, id)
+	var user_id int32
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const getUserPostBySha256 = `-- name: GetUserPostBySha256 :one
+SELECT id, user_id, image_path, content_type, size_bytes, sha256, deleted_at, created_at FROM posts
+WHERE user_id = $1 AND sha256 = $2 AND deleted_at IS NULL
+ORDER BY id LIMIT 1
+`
+
+type GetUserPostBySha256Params struct {
+	UserID int32
+	Sha256 string
+}
+
+func (q *Queries) GetUserPostBySha256(ctx context.Context, arg GetUserPostBySha256Params) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getUserPostBySha256, arg.UserID, arg.Sha256)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ImagePath,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.DeletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const listPosts = `-- name: ListPosts :many
-SELECT posts.id, posts.user_id, posts.image_path, users.name as user_name FROM posts 
+SELECT posts.id, posts.user_id, posts.image_path, posts.content_type, posts.size_bytes, posts.sha256, posts.deleted_at, posts.created_at, users.name as user_name FROM posts 
 INNER JOIN users ON posts.user_id = users.id
+WHERE posts.deleted_at IS NULL
 ORDER BY posts.id LIMIT $1 OFFSET $2
 `
 
@@ -94,10 +174,15 @@ type ListPostsParams struct {
 }
 
 type ListPostsRow struct {
-	ID        int32
-	UserID    int32
-	ImagePath string
-	UserName  string
+	ID          int32
+	UserID      int32
+	ImagePath   string
+	ContentType string
+	SizeBytes   int64
+	Sha256      string
+	DeletedAt   sql.NullTime
+	CreatedAt   time.Time
+	UserName    string
 }
 
 func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]ListPostsRow, error) {
@@ -113,6 +198,11 @@ func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]ListPos
 			&i.ID,
 			&i.UserID,
 			&i.ImagePath,
+			&i.ContentType,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.DeletedAt,
+			&i.CreatedAt,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -127,3 +217,94 @@ func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]ListPos
 	}
 	return items, nil
 }
+
+const listTrashedPosts = `-- name: ListTrashedPosts :many
+SELECT id, user_id, image_path, content_type, size_bytes, sha256, deleted_at, created_at FROM posts
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) ListTrashedPosts(ctx context.Context, userID int32) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedPosts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImagePath,
+			&i.ContentType,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.DeletedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeTrashedPosts = `-- name: PurgeTrashedPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT id FROM posts
+    WHERE deleted_at < $1::timestamp
+    ORDER BY deleted_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type PurgeTrashedPostsParams struct {
+	DeletedBefore time.Time
+	BatchSize     int32
+}
+
+func (q *Queries) PurgeTrashedPosts(ctx context.Context, arg PurgeTrashedPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeTrashedPosts, arg.DeletedBefore, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restorePost = `-- name: RestorePost :execrows
+UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) RestorePost(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restorePost, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const trashPost = `-- name: TrashPost :execrows
+UPDATE posts SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type TrashPostParams struct {
+	ID        int32
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) TrashPost(ctx context.Context, arg TrashPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, trashPost, arg.ID, arg.DeletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
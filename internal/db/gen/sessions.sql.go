@@ -8,11 +8,24 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+const countActiveSessions = `-- name: CountActiveSessions :one
+SELECT count(*) FROM sessions
+WHERE NOT is_revoked AND expires_at > $1::timestamp
+`
+
+func (q *Queries) CountActiveSessions(ctx context.Context, now time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveSessions, now)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO sessions (id, user_login, access_token, refresh_token, is_revoked, expires_at)
-VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, user_login, access_token, refresh_token, is_revoked, created_at, expires_at
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, user_login, access_token, refresh_token, is_revoked, created_at, expires_at, revoked_at
 `
 
 type CreateSessionParams struct {
@@ -42,6 +55,7 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.IsRevoked,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
@@ -56,7 +70,7 @@ func (q *Queries) DeletSession(ctx context.Context, id string) error {
 }
 
 const getSession = `-- name: GetSession :one
-SELECT id, user_login, access_token, refresh_token, is_revoked, created_at, expires_at FROM sessions WHERE id = $1
+SELECT id, user_login, access_token, refresh_token, is_revoked, created_at, expires_at, revoked_at FROM sessions WHERE id = $1
 `
 
 func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
@@ -70,10 +84,55 @@ func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
 		&i.IsRevoked,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
 
+const getSessionBacklog = `-- name: GetSessionBacklog :one
+SELECT count(*) AS depth,
+       COALESCE(EXTRACT(EPOCH FROM ($1::timestamp - MIN(expires_at))), 0)::float8 AS oldest_seconds
+FROM sessions
+WHERE expires_at < $1::timestamp
+`
+
+type GetSessionBacklogRow struct {
+	Depth         int64
+	OldestSeconds float64
+}
+
+func (q *Queries) GetSessionBacklog(ctx context.Context, now time.Time) (GetSessionBacklogRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionBacklog, now)
+	var i GetSessionBacklogRow
+	err := row.Scan(&i.Depth, &i.OldestSeconds)
+	return i, err
+}
+
+const purgeSessions = `-- name: PurgeSessions :execrows
+DELETE FROM sessions
+WHERE id IN (
+    SELECT id FROM sessions
+    WHERE expires_at < $1::timestamp
+       OR (is_revoked AND COALESCE(revoked_at, created_at) < $1::timestamp - make_interval(secs => $2::float8))
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type PurgeSessionsParams struct {
+	Now                     time.Time
+	RevokedRetentionSeconds float64
+	BatchSize               int32
+}
+
+func (q *Queries) PurgeSessions(ctx context.Context, arg PurgeSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeSessions, arg.Now, arg.RevokedRetentionSeconds, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const renewSession = `-- name: RenewSession :exec
 UPDATE sessions
 SET access_token = $2
@@ -92,22 +151,32 @@ func (q *Queries) RenewSession(ctx context.Context, arg RenewSessionParams) erro
 
 const revokeSession = `-- name: RevokeSession :exec
 UPDATE sessions
-SET is_revoked = TRUE
-WHERE access_token = $1
+SET is_revoked = TRUE, revoked_at = COALESCE(revoked_at, $1::timestamp)
+WHERE access_token = $2
 `
 
-func (q *Queries) RevokeSession(ctx context.Context, accessToken string) error {
-	_, err := q.db.ExecContext(ctx, revokeSession, accessToken)
+type RevokeSessionParams struct {
+	RevokedAt   time.Time
+	AccessToken string
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) error {
+	_, err := q.db.ExecContext(ctx, revokeSession, arg.RevokedAt, arg.AccessToken)
 	return err
 }
 
 const revokeSessionsByLogin = `-- name: RevokeSessionsByLogin :exec
 UPDATE sessions
-SET is_revoked = TRUE
-WHERE user_login = $1
+SET is_revoked = TRUE, revoked_at = COALESCE(revoked_at, $1::timestamp)
+WHERE user_login = $2
 `
 
-func (q *Queries) RevokeSessionsByLogin(ctx context.Context, userLogin string) error {
-	_, err := q.db.ExecContext(ctx, revokeSessionsByLogin, userLogin)
+type RevokeSessionsByLoginParams struct {
+	RevokedAt time.Time
+	UserLogin string
+}
+
+func (q *Queries) RevokeSessionsByLogin(ctx context.Context, arg RevokeSessionsByLoginParams) error {
+	_, err := q.db.ExecContext(ctx, revokeSessionsByLogin, arg.RevokedAt, arg.UserLogin)
 	return err
 }
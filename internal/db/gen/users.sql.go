@@ -119,6 +119,22 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const updatePasswordHash = `-- name: UpdatePasswordHash :exec
+UPDATE users_auth
+SET password_hash = $2
+WHERE user_id = $1
+`
+
+type UpdatePasswordHashParams struct {
+	UserID       int32
+	PasswordHash string
+}
+
+func (q *Queries) UpdatePasswordHash(ctx context.Context, arg UpdatePasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, updatePasswordHash, arg.UserID, arg.PasswordHash)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :exec
 UPDATE users
 SET name = $2, description = $3
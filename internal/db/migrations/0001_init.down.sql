@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users_auth;
+DROP TABLE IF EXISTS users;
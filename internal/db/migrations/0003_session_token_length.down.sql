@@ -0,0 +1,2 @@
+ALTER TABLE sessions ALTER COLUMN access_token TYPE VARCHAR(512);
+ALTER TABLE sessions ALTER COLUMN refresh_token TYPE VARCHAR(512);
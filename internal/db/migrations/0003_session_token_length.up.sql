@@ -0,0 +1,3 @@
+-- RS256 tokens don't fit into 512 characters.
+ALTER TABLE sessions ALTER COLUMN access_token TYPE TEXT;
+ALTER TABLE sessions ALTER COLUMN refresh_token TYPE TEXT;
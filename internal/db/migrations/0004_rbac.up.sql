@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    description TEXT,
+    is_builtin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INT NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role_id, permission),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('post.delete.any', 'Delete posts of any user'),
+    ('user.update.any', 'Update profiles of any user'),
+    ('user.delete.any', 'Delete any user'),
+    ('user.ban', 'Ban users'),
+    ('report.review', 'Review reported content'),
+    ('role.manage', 'Create roles and assign them to users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, is_builtin) VALUES
+    ('user', 'Regular user', TRUE),
+    ('moderator', 'Moderates content', TRUE),
+    ('admin', 'Full access', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, permissions.name FROM roles, permissions
+WHERE roles.name = 'admin'
+   OR (roles.name = 'moderator' AND permissions.name IN ('post.delete.any', 'user.ban', 'report.review'))
+ON CONFLICT DO NOTHING;
+
+-- Users created before roles existed keep their access level.
+INSERT INTO user_roles (user_id, role_id)
+SELECT users_auth.user_id, roles.id FROM users_auth, roles
+WHERE roles.name = CASE WHEN users_auth.is_admin THEN 'admin' ELSE 'user' END
+  AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users_auth.user_id)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE name = 'auth.unlock';
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITHOUT TIME ZONE,
+    locked BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('auth.unlock', 'Lift login lockouts')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, 'auth.unlock' FROM roles WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITHOUT TIME ZONE;
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS sha256,
+    DROP COLUMN IF EXISTS size_bytes,
+    DROP COLUMN IF EXISTS content_type;
@@ -0,0 +1,4 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS content_type VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS size_bytes BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS posts_blob_refs ON posts;
+DROP FUNCTION IF EXISTS posts_blob_refs();
+DROP INDEX IF EXISTS posts_sha256_idx;
+DROP TABLE IF EXISTS blobs;
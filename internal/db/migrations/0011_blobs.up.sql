@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS blobs (
+    sha256 VARCHAR(64) PRIMARY KEY,
+    path VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    ref_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS posts_sha256_idx ON posts (sha256);
+
+-- Posts stored since 0009 already know their hash. Duplicates keep the file of
+-- the oldest post, the other copies are left for garbage collection.
+INSERT INTO blobs (sha256, path, size_bytes, content_type, ref_count)
+SELECT DISTINCT ON (sha256) sha256, image_path, size_bytes, content_type, COUNT(*) OVER (PARTITION BY sha256)
+FROM posts
+WHERE sha256 <> ''
+ORDER BY sha256, id
+ON CONFLICT (sha256) DO NOTHING;
+
+UPDATE posts SET image_path = blobs.path
+FROM blobs
+WHERE posts.sha256 = blobs.sha256 AND posts.image_path <> blobs.path;
+
+-- Reference counts follow the posts table, including cascading deletes of users.
+CREATE OR REPLACE FUNCTION posts_blob_refs() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count + 1 WHERE sha256 = NEW.sha256;
+    ELSIF TG_OP = 'DELETE' AND OLD.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE sha256 = OLD.sha256;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_blob_refs ON posts;
+CREATE TRIGGER posts_blob_refs
+    AFTER INSERT OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_blob_refs();
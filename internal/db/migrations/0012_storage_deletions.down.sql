@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION posts_blob_refs() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count + 1 WHERE sha256 = NEW.sha256;
+    ELSIF TG_OP = 'DELETE' AND OLD.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE sha256 = OLD.sha256;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS storage_deletions;
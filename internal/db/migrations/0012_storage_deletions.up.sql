@@ -0,0 +1,29 @@
+-- Outbox of files to remove. Rows are written in the same transaction that
+-- deletes the last reference and processed by a background worker.
+CREATE TABLE IF NOT EXISTS storage_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    path VARCHAR(255) NOT NULL,
+    sha256 VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS storage_deletions_next_attempt_at_idx ON storage_deletions (next_attempt_at);
+
+CREATE OR REPLACE FUNCTION posts_blob_refs() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count + 1 WHERE sha256 = NEW.sha256;
+    ELSIF TG_OP = 'DELETE' AND OLD.sha256 <> '' THEN
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE sha256 = OLD.sha256;
+        INSERT INTO storage_deletions (path, sha256)
+        SELECT path, sha256 FROM blobs WHERE sha256 = OLD.sha256 AND ref_count <= 0;
+    ELSIF TG_OP = 'DELETE' THEN
+        -- Posts from before content addressing own their file alone.
+        INSERT INTO storage_deletions (path) VALUES (OLD.image_path);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
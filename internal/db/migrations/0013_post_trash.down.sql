@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE name = 'post.purge';
+DROP INDEX IF EXISTS posts_deleted_at_idx;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'quota.manage';
+DROP INDEX IF EXISTS posts_user_id_created_at_idx;
+ALTER TABLE posts DROP COLUMN IF EXISTS created_at;
+DROP TABLE IF EXISTS user_quotas;
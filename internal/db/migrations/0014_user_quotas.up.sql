@@ -0,0 +1,22 @@
+-- Per-user overrides of the configured quota defaults, NULL keeps the default.
+CREATE TABLE IF NOT EXISTS user_quotas (
+    user_id INT PRIMARY KEY,
+    max_bytes BIGINT,
+    max_posts BIGINT,
+    max_file_size BIGINT,
+    max_uploads_per_day BIGINT,
+    updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS posts_user_id_created_at_idx ON posts (user_id, created_at);
+
+INSERT INTO permissions (name, description) VALUES
+    ('quota.manage', 'Override storage quotas of users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, 'quota.manage' FROM roles WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+-- Original EXIF of posts whose owner asked to keep it. Only the owner can read it,
+-- the stored file itself is sanitized.
+CREATE TABLE IF NOT EXISTS post_metadata (
+    post_id INT PRIMARY KEY,
+    exif BYTEA NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
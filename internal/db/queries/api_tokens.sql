@@ -0,0 +1,23 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, prefix, token_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY id;
+
+-- name: GetAPITokenByPrefix :one
+SELECT api_tokens.*, users_auth.login FROM api_tokens
+INNER JOIN users_auth ON api_tokens.user_id = users_auth.user_id
+WHERE api_tokens.prefix = $1;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
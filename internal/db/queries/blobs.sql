@@ -0,0 +1,11 @@
+-- name: AcquireBlob :one
+-- Returns the blob of the hash, creating it if needed. The row stays locked
+-- until the transaction ends so a concurrent release cannot remove the file.
+INSERT INTO blobs (sha256, path, size_bytes, content_type)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (sha256) DO UPDATE SET sha256 = EXCLUDED.sha256
+RETURNING *;
+
+-- name: ReleaseBlob :one
+DELETE FROM blobs WHERE sha256 = $1 AND ref_count <= 0
+RETURNING path;
@@ -0,0 +1,27 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts WHERE key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_attempts.last_failure_at < sqlc.arg(window_start) THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = sqlc.arg(now),
+    locked = login_attempts.locked AND login_attempts.blocked_until > sqlc.arg(now)
+RETURNING *;
+
+-- name: BlockLoginAttempt :exec
+UPDATE login_attempts
+SET blocked_until = $2, locked = $3
+WHERE key = $1;
+
+-- name: DeleteLoginAttempt :execrows
+DELETE FROM login_attempts WHERE key = $1;
+
+-- name: ListLockedLoginAttempts :many
+SELECT * FROM login_attempts
+WHERE locked AND blocked_until > $1
+ORDER BY blocked_until DESC;
@@ -0,0 +1,5 @@
+-- name: CreatePostMetadata :exec
+INSERT INTO post_metadata (post_id, exif) VALUES ($1, $2);
+
+-- name: GetPostMetadata :one
+SELECT exif FROM post_metadata WHERE post_id = $1;
@@ -1,22 +1,52 @@
 -- name: GetPost :one
 SELECT posts.*, users.name as user_name FROM posts 
 INNER JOIN users ON posts.user_id = users.id
-WHERE posts.id = $1;
+WHERE posts.id = $1 AND posts.deleted_at IS NULL;
 
 -- name: ListPosts :many
 SELECT posts.*, users.name as user_name FROM posts 
 INNER JOIN users ON posts.user_id = users.id
+WHERE posts.deleted_at IS NULL
 ORDER BY posts.id LIMIT $1 OFFSET $2;
 
 -- name: CountPosts :one
-SELECT count(*) FROM posts;
+SELECT count(*) FROM posts WHERE deleted_at IS NULL;
 
 -- name: CreatePost :one
-INSERT INTO posts (user_id, image_path)
-VALUES ($1, $2) RETURNING *;
+INSERT INTO posts (user_id, image_path, content_type, size_bytes, sha256)
+VALUES ($1, $2, $3, $4, $5) RETURNING *;
 
 -- name: GetPostUserID :one
-SELECT user_id FROM posts WHERE posts.id = $1;
+SELECT user_id FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NULL;
 
--- name: DeletPost :exec
-DELETE FROM posts WHERE id = $1;
\ No newline at end of file
+-- name: GetTrashedPostUserID :one
+SELECT user_id FROM posts WHERE posts.id = $1 AND posts.deleted_at IS NOT NULL;
+
+-- name: TrashPost :execrows
+UPDATE posts SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestorePost :execrows
+UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: ListTrashedPosts :many
+SELECT * FROM posts
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: DeletePost :one
+DELETE FROM posts WHERE id = $1 RETURNING *;
+
+-- name: PurgeTrashedPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT id FROM posts
+    WHERE deleted_at < sqlc.arg(deleted_before)::timestamp
+    ORDER BY deleted_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: GetUserPostBySha256 :one
+SELECT * FROM posts
+WHERE user_id = $1 AND sha256 = $2 AND deleted_at IS NULL
+ORDER BY id LIMIT 1;
@@ -0,0 +1,32 @@
+-- name: LockUserQuota :one
+-- Serializes quota checks of a user until the transaction ends.
+SELECT id FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: GetUserQuota :one
+SELECT * FROM user_quotas WHERE user_id = $1;
+
+-- name: SetUserQuota :one
+INSERT INTO user_quotas (user_id, max_bytes, max_posts, max_file_size, max_uploads_per_day, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET max_bytes = EXCLUDED.max_bytes,
+    max_posts = EXCLUDED.max_posts,
+    max_file_size = EXCLUDED.max_file_size,
+    max_uploads_per_day = EXCLUDED.max_uploads_per_day,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: DeleteUserQuota :execrows
+DELETE FROM user_quotas WHERE user_id = $1;
+
+-- name: GetUserUsage :one
+-- Posts in the trash still count, their files are kept until purged. Resumable
+-- uploads still being sent reserve their full length, complete ones are about
+-- to be counted as posts.
+SELECT COALESCE(SUM(size_bytes), 0)::bigint AS bytes,
+       count(*) AS posts,
+       count(*) FILTER (WHERE created_at >= sqlc.arg(since)::timestamp) AS uploads_since,
+       (SELECT COALESCE(SUM(length), 0)::bigint FROM uploads
+        WHERE uploads.user_id = sqlc.arg(user_id) AND post_id IS NULL AND upload_offset < length) AS pending_bytes
+FROM posts
+WHERE user_id = sqlc.arg(user_id);
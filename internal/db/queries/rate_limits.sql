@@ -0,0 +1,20 @@
+-- name: TakeRateLimit :one
+INSERT INTO rate_limits (key, tat)
+VALUES (sqlc.arg(key), sqlc.arg(now)::timestamp + make_interval(secs => sqlc.arg(interval_seconds)::float8))
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(rate_limits.tat, sqlc.arg(now)::timestamp) + make_interval(secs => sqlc.arg(interval_seconds)::float8)
+WHERE GREATEST(rate_limits.tat, sqlc.arg(now)::timestamp) + make_interval(secs => sqlc.arg(interval_seconds)::float8)
+   <= sqlc.arg(now)::timestamp + make_interval(secs => sqlc.arg(burst_seconds)::float8)
+RETURNING tat;
+
+-- name: GetRateLimit :one
+SELECT tat FROM rate_limits WHERE key = $1;
+
+-- name: PurgeRateLimits :execrows
+DELETE FROM rate_limits
+WHERE key IN (
+    SELECT key FROM rate_limits
+    WHERE tat < sqlc.arg(now)::timestamp
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
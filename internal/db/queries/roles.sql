@@ -0,0 +1,41 @@
+-- name: ListRoles :many
+SELECT * FROM roles ORDER BY id;
+
+-- name: GetRoleByName :one
+SELECT * FROM roles WHERE name = $1;
+
+-- name: CreateRole :one
+INSERT INTO roles (name, description)
+VALUES ($1, $2) RETURNING *;
+
+-- name: DeleteRole :execrows
+DELETE FROM roles WHERE id = $1 AND is_builtin = FALSE;
+
+-- name: ListPermissions :many
+SELECT * FROM permissions ORDER BY name;
+
+-- name: ListRolePermissions :many
+SELECT * FROM role_permissions ORDER BY role_id, permission;
+
+-- name: AddRolePermission :exec
+INSERT INTO role_permissions (role_id, permission)
+VALUES ($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: ListUserRoles :many
+SELECT roles.* FROM roles
+INNER JOIN user_roles ON user_roles.role_id = roles.id
+WHERE user_roles.user_id = $1
+ORDER BY roles.id;
+
+-- name: AssignUserRole :exec
+INSERT INTO user_roles (user_id, role_id)
+VALUES ($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: RemoveUserRole :execrows
+DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2;
+
+-- name: GetUserPermissions :many
+SELECT DISTINCT role_permissions.permission FROM user_roles
+INNER JOIN role_permissions ON role_permissions.role_id = user_roles.role_id
+WHERE user_roles.user_id = $1
+ORDER BY role_permissions.permission;
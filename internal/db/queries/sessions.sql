@@ -7,13 +7,13 @@ VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
 
 -- name: RevokeSession :exec
 UPDATE sessions
-SET is_revoked = TRUE
-WHERE access_token = $1;
+SET is_revoked = TRUE, revoked_at = COALESCE(revoked_at, sqlc.arg(revoked_at)::timestamp)
+WHERE access_token = sqlc.arg(access_token);
 
 -- name: RevokeSessionsByLogin :exec
 UPDATE sessions
-SET is_revoked = TRUE
-WHERE user_login = $1;
+SET is_revoked = TRUE, revoked_at = COALESCE(revoked_at, sqlc.arg(revoked_at)::timestamp)
+WHERE user_login = sqlc.arg(user_login);
 
 -- name: RenewSession :exec
 UPDATE sessions
@@ -21,4 +21,24 @@ SET access_token = $2
 WHERE id = $1;
 
 -- name: DeletSession :exec
-DELETE FROM sessions WHERE id = $1;
\ No newline at end of file
+DELETE FROM sessions WHERE id = $1;
+
+-- name: PurgeSessions :execrows
+DELETE FROM sessions
+WHERE id IN (
+    SELECT id FROM sessions
+    WHERE expires_at < sqlc.arg(now)::timestamp
+       OR (is_revoked AND COALESCE(revoked_at, created_at) < sqlc.arg(now)::timestamp - make_interval(secs => sqlc.arg(revoked_retention_seconds)::float8))
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: CountActiveSessions :one
+SELECT count(*) FROM sessions
+WHERE NOT is_revoked AND expires_at > sqlc.arg(now)::timestamp;
+
+-- name: GetSessionBacklog :one
+SELECT count(*) AS depth,
+       COALESCE(EXTRACT(EPOCH FROM (sqlc.arg(now)::timestamp - MIN(expires_at))), 0)::float8 AS oldest_seconds
+FROM sessions
+WHERE expires_at < sqlc.arg(now)::timestamp;
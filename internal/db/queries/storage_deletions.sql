@@ -0,0 +1,27 @@
+-- name: ClaimStorageDeletion :one
+SELECT * FROM storage_deletions
+WHERE next_attempt_at <= $1
+ORDER BY id
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteStorageDeletion :exec
+DELETE FROM storage_deletions WHERE id = $1;
+
+-- name: FailStorageDeletion :exec
+UPDATE storage_deletions
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: GetStorageDeletionBacklog :one
+SELECT count(*) AS depth,
+       COALESCE(EXTRACT(EPOCH FROM (sqlc.arg(now)::timestamp - MIN(created_at))), 0)::float8 AS oldest_seconds
+FROM storage_deletions;
+
+-- name: ListStoragePaths :many
+-- Every path the database still knows about, used by gc to find orphan files.
+SELECT image_path AS path FROM posts
+UNION
+SELECT path FROM blobs
+UNION
+SELECT path FROM storage_deletions;
@@ -0,0 +1,34 @@
+-- name: CreateUpload :one
+INSERT INTO uploads (id, user_id, length, metadata, expires_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetUpload :one
+SELECT * FROM uploads WHERE id = $1;
+
+-- name: AdvanceUpload :execrows
+UPDATE uploads
+SET upload_offset = sqlc.arg(new_offset), expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id) AND upload_offset = sqlc.arg(old_offset);
+
+-- name: CompleteUpload :exec
+UPDATE uploads SET post_id = $2 WHERE id = $1;
+
+-- name: DeleteUpload :execrows
+DELETE FROM uploads WHERE id = $1;
+
+-- name: ListExpiredUploads :many
+SELECT * FROM uploads
+WHERE expires_at < $1
+ORDER BY expires_at
+LIMIT $2;
+
+-- name: ListUploadIDs :many
+SELECT id FROM uploads;
+
+-- name: LockUpload :one
+-- Holds the upload until the transaction ends, another holder makes it return no rows.
+SELECT upload_offset FROM uploads WHERE id = $1 FOR UPDATE SKIP LOCKED;
+
+-- name: ClaimUpload :one
+-- Waits for the request publishing the upload, if any, and returns the post it became.
+SELECT post_id FROM uploads WHERE id = $1 FOR UPDATE;
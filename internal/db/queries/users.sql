@@ -20,6 +20,11 @@ SELECT * FROM users_auth WHERE login = $1 LIMIT 1;
 INSERT INTO users_auth (user_id ,login, password_hash)
 VALUES ($1, $2, $3);
 
+-- name: UpdatePasswordHash :exec
+UPDATE users_auth
+SET password_hash = $2
+WHERE user_id = $1;
+
 -- name: UpdateUser :exec
 UPDATE users
 SET name = $2, description = $3
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"image-sharing/internal/migrate"
+)
+
+func DatabaseCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// MigrationsCheck fails while the database schema is behind the binary.
+func MigrationsCheck(migrator *migrate.Migrator) Check {
+	return func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations", len(pending))
+		}
+		return nil
+	}
+}
+
+// StorageCheck writes and removes a small file in dir.
+func StorageCheck(dir string) Check {
+	return func(ctx context.Context) error {
+		file, err := os.CreateTemp(dir, ".readyz-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		if _, err := file.Write([]byte("ok")); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+}
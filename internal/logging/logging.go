@@ -0,0 +1,77 @@
+// Package logging carries a request-scoped slog.Logger through the context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New builds the process logger in text or JSON format.
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+type contextKey struct{}
+
+// requestState is shared by all middlewares of one request, so values set by
+// inner middlewares (the authenticated user) are visible to the access log.
+type requestState struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	userID int32
+}
+
+// WithLogger stores a request logger in ctx.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestState{logger: logger})
+}
+
+// SetUserID adds the authenticated user to the request logger.
+func SetUserID(ctx context.Context, userID int32) {
+	state, ok := ctx.Value(contextKey{}).(*requestState)
+	if !ok {
+		return
+	}
+	state.mu.Lock()
+	state.userID = userID
+	state.mu.Unlock()
+}
+
+// FromContext returns the request logger with the matched route and user, or
+// the default logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	state, ok := ctx.Value(contextKey{}).(*requestState)
+	if !ok {
+		return slog.Default()
+	}
+	state.mu.Lock()
+	logger, userID := state.logger, state.userID
+	state.mu.Unlock()
+
+	if routeContext := chi.RouteContext(ctx); routeContext != nil {
+		if pattern := routeContext.RoutePattern(); pattern != "" {
+			logger = logger.With("route", pattern)
+		}
+	}
+	if userID != 0 {
+		logger = logger.With("user_id", userID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With("trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+	}
+	return logger
+}
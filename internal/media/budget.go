@@ -0,0 +1,68 @@
+package media
+
+import (
+	"io"
+	"sync"
+)
+
+// decodeBytesPerPixel estimates the memory a full decode takes per pixel:
+// 16 bit PNGs decode to 8 bytes a pixel, progressive JPEGs keep their
+// coefficients next to the image.
+const decodeBytesPerPixel = 10
+
+// Limits bound the resources validating an upload may take.
+type Limits struct {
+	// MaxPixels is the largest width times height of an image.
+	MaxPixels int64
+	// Memory is shared by all decodes, nil leaves them unbounded.
+	Memory *Budget
+}
+
+// Budget is memory shared by the image decodes of all uploads. A decode waits
+// until its estimated share is free, one larger than the whole budget runs
+// alone.
+type Budget struct {
+	mu    sync.Mutex
+	size  int64
+	used  int64
+	freed chan struct{}
+}
+
+func NewBudget(size int64) *Budget {
+	return &Budget{size: size, freed: make(chan struct{})}
+}
+
+// acquire reserves n bytes and returns how many were reserved. It gives up
+// with io.ErrClosedPipe once stop is closed.
+func (b *Budget) acquire(n int64, stop <-chan struct{}) (int64, error) {
+	if b == nil {
+		return 0, nil
+	}
+	n = min(n, b.size)
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.size {
+			b.used += n
+			b.mu.Unlock()
+			return n, nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+		select {
+		case <-freed:
+		case <-stop:
+			return 0, io.ErrClosedPipe
+		}
+	}
+}
+
+func (b *Budget) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+	b.mu.Unlock()
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reasons media is rejected. They are stable and reported to clients.
+const (
+	ReasonMalformed     = "malformed"
+	ReasonTruncated     = "truncated"
+	ReasonTrailingData  = "trailing_data"
+	ReasonTooManyPixels = "too_many_pixels"
+)
+
+// ValidationError tells why media was rejected.
+type ValidationError struct {
+	Reason string
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Detail == "" {
+		return "invalid media: " + e.Reason
+	}
+	return "invalid media: " + e.Reason + ": " + e.Detail
+}
+
+var (
+	// ErrMalformed is returned when media does not follow its format.
+	ErrMalformed = &ValidationError{Reason: ReasonMalformed}
+	// ErrTruncated is returned when media ends before its format says it should.
+	ErrTruncated = &ValidationError{Reason: ReasonTruncated}
+)
+
+// IsInvalid reports whether err was caused by the media itself.
+func IsInvalid(err error) bool {
+	var invalid *ValidationError
+	return errors.As(err, &invalid)
+}
+
+func invalid(reason string, format string, args ...any) error {
+	return &ValidationError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// malformed reports a source ending early as truncated media and passes other
+// errors, such as upload limits, through.
+func malformed(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package media
+
+import "encoding/binary"
+
+const (
+	tagOrientation = 0x0112
+	typeShort      = 3
+)
+
+// orientation reads the Orientation tag of IFD0 from a TIFF structured EXIF
+// block. Zero means the tag is missing or the block cannot be read.
+func orientation(exif []byte) uint16 {
+	if len(exif) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(exif[2:]) != 42 {
+		return 0
+	}
+	offset := int64(order.Uint32(exif[4:]))
+	if offset+2 > int64(len(exif)) {
+		return 0
+	}
+	count := int64(order.Uint16(exif[offset:]))
+	for i := int64(0); i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > int64(len(exif)) {
+			return 0
+		}
+		if order.Uint16(exif[entry:]) != tagOrientation {
+			continue
+		}
+		if order.Uint16(exif[entry+2:]) != typeShort || order.Uint32(exif[entry+4:]) != 1 {
+			return 0
+		}
+		if value := order.Uint16(exif[entry+8:]); value >= 1 && value <= 8 {
+			return value
+		}
+		return 0
+	}
+	return 0
+}
+
+// orientationEXIF builds an EXIF block holding nothing but the orientation.
+func orientationEXIF(value uint16) []byte {
+	exif := make([]byte, 26)
+	copy(exif, "MM")
+	binary.BigEndian.PutUint16(exif[2:], 42)
+	binary.BigEndian.PutUint32(exif[4:], 8)
+	binary.BigEndian.PutUint16(exif[8:], 1)
+	binary.BigEndian.PutUint16(exif[10:], tagOrientation)
+	binary.BigEndian.PutUint16(exif[12:], typeShort)
+	binary.BigEndian.PutUint32(exif[14:], 1)
+	binary.BigEndian.PutUint16(exif[18:], value)
+	// The next IFD offset in exif[22:26] stays zero.
+	return exif
+}
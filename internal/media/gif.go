@@ -0,0 +1,163 @@
+package media
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2C
+	gifTrailer    = 0x3B
+	gifColorTable = 0x80
+)
+
+// maxAnimationFactor bounds the pixels of all frames of an animation, as a
+// multiple of the limit for a single image.
+const maxAnimationFactor = 10
+
+// checkGIF decodes every frame of a GIF without keeping the pixels, so long
+// animations do not need memory for all of their frames.
+func checkGIF(r *bufio.Reader, maxPixels int64) error {
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return malformed(err)
+	}
+	if signature := string(header[:6]); signature != "GIF87a" && signature != "GIF89a" {
+		return invalid(ReasonMalformed, "missing GIF signature")
+	}
+	width := int64(binary.LittleEndian.Uint16(header[6:]))
+	height := int64(binary.LittleEndian.Uint16(header[8:]))
+	if width*height > maxPixels {
+		return invalid(ReasonTooManyPixels, "%dx%d is more than %d pixels", width, height, maxPixels)
+	}
+	if err := skipColorTable(r, header[10]); err != nil {
+		return err
+	}
+
+	budget := maxPixels * maxAnimationFactor
+	frames := 0
+	for {
+		block, err := r.ReadByte()
+		if err != nil {
+			return malformed(err)
+		}
+		switch block {
+		case gifExtension:
+			if _, err := r.ReadByte(); err != nil {
+				return malformed(err)
+			}
+			if _, err := io.Copy(io.Discard, &blockReader{r: r}); err != nil {
+				return err
+			}
+		case gifImage:
+			var descriptor [9]byte
+			if _, err := io.ReadFull(r, descriptor[:]); err != nil {
+				return malformed(err)
+			}
+			left := int64(binary.LittleEndian.Uint16(descriptor[0:]))
+			top := int64(binary.LittleEndian.Uint16(descriptor[2:]))
+			frameWidth := int64(binary.LittleEndian.Uint16(descriptor[4:]))
+			frameHeight := int64(binary.LittleEndian.Uint16(descriptor[6:]))
+			if left+frameWidth > width || top+frameHeight > height {
+				return invalid(ReasonMalformed, "GIF frame %d outside of the image", frames)
+			}
+			budget -= frameWidth * frameHeight
+			if budget < 0 {
+				return invalid(ReasonTooManyPixels, "GIF frames exceed %d pixels", maxPixels*maxAnimationFactor)
+			}
+			if err := skipColorTable(r, descriptor[8]); err != nil {
+				return err
+			}
+			if err := decodeFrame(r, frameWidth*frameHeight); err != nil {
+				return err
+			}
+			frames++
+		case gifTrailer:
+			if frames == 0 {
+				return invalid(ReasonMalformed, "GIF without frames")
+			}
+			return nil
+		default:
+			return invalid(ReasonMalformed, "unknown GIF block 0x%02x", block)
+		}
+	}
+}
+
+// decodeFrame decompresses the image data of a frame, which has to hold
+// exactly pixels pixels.
+func decodeFrame(r *bufio.Reader, pixels int64) error {
+	litWidth, err := r.ReadByte()
+	if err != nil {
+		return malformed(err)
+	}
+	if litWidth < 2 || litWidth > 8 {
+		return invalid(ReasonMalformed, "GIF literal width %d", litWidth)
+	}
+	blocks := &blockReader{r: r}
+	decompressor := lzw.NewReader(blocks, lzw.LSB, int(litWidth))
+	decoded, err := io.Copy(io.Discard, io.LimitReader(decompressor, pixels+1))
+	decompressor.Close()
+	if blocks.err != nil {
+		return blocks.err
+	}
+	// Like image/gif, a frame may end without the end of data code.
+	if err != nil && !(err == io.ErrUnexpectedEOF && decoded == pixels) {
+		return invalid(ReasonMalformed, "GIF frame: %s", err)
+	}
+	if decoded != pixels {
+		return invalid(ReasonMalformed, "GIF frame has %d of %d pixels", min(decoded, pixels+1), pixels)
+	}
+	_, err = io.Copy(io.Discard, blocks)
+	return err
+}
+
+func skipColorTable(r *bufio.Reader, flags byte) error {
+	if flags&gifColorTable == 0 {
+		return nil
+	}
+	_, err := io.CopyN(io.Discard, r, 3<<(flags&0x07+1))
+	return malformed(err)
+}
+
+// blockReader reads the data sub-blocks of a GIF block as one stream. Errors
+// of the source are kept apart from the end of the data.
+type blockReader struct {
+	r    *bufio.Reader
+	left int
+	done bool
+	err  error
+}
+
+func (b *blockReader) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	for b.left == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		size, err := b.r.ReadByte()
+		if err != nil {
+			b.err = malformed(err)
+			return 0, b.err
+		}
+		if size == 0 {
+			b.done = true
+			return 0, io.EOF
+		}
+		b.left = int(size)
+	}
+	if len(p) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= n
+	if err != nil {
+		b.err = malformed(err)
+		return n, b.err
+	}
+	return n, nil
+}
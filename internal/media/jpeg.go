@@ -0,0 +1,194 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	markerSOI   = 0xD8
+	markerEOI   = 0xD9
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP14 = 0xEE
+	markerAPP15 = 0xEF
+	markerCOM   = 0xFE
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+)
+
+// jpeg copies the segments of a JPEG image, dropping APP1 (EXIF, XMP), APP13
+// (IPTC), comments and the other application segments except JFIF, the ICC
+// profile and the Adobe color transform. EXIF is replaced by a block with the
+// orientation only.
+func (s *Sanitizer) jpeg(w io.Writer, src io.Reader) error {
+	r := bufio.NewReader(src)
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return malformed(err)
+	}
+	if soi[0] != 0xFF || soi[1] != markerSOI {
+		return invalid(ReasonMalformed, "missing JPEG start of image")
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	marker, err := readMarker(r)
+	for ; err == nil; marker, err = nextMarker(r, marker) {
+		if marker == markerEOI {
+			// Trailing data, such as the extra images of MPO files, is dropped.
+			_, err = w.Write([]byte{0xFF, marker})
+			return err
+		}
+		if marker == 0x01 || isRestart(marker) {
+			if _, err = w.Write([]byte{0xFF, marker}); err != nil {
+				return err
+			}
+			if isRestart(marker) {
+				// Restart markers sit inside a scan, which goes on after them.
+				if err = copyScan(w, r); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		var length [2]byte
+		if _, err = io.ReadFull(r, length[:]); err != nil {
+			return malformed(err)
+		}
+		size := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if size < 0 {
+			return invalid(ReasonMalformed, "JPEG segment length %d", size+2)
+		}
+
+		if marker >= markerAPP0 && marker <= markerAPP15 || marker == markerCOM {
+			payload := make([]byte, size)
+			if _, err = io.ReadFull(r, payload); err != nil {
+				return malformed(err)
+			}
+			if err = s.jpegApp(w, marker, payload); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err = w.Write([]byte{0xFF, marker, length[0], length[1]}); err != nil {
+			return err
+		}
+		if _, err = io.CopyN(w, r, size); err != nil {
+			return malformed(err)
+		}
+		if marker == markerSOS {
+			if err = copyScan(w, r); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// jpegApp writes the application segment if it is safe to keep.
+func (s *Sanitizer) jpegApp(w io.Writer, marker byte, payload []byte) error {
+	switch {
+	case s.passthrough:
+		return writeSegment(w, marker, payload)
+	case marker == markerAPP0, marker == markerAPP14,
+		marker == markerAPP2 && bytes.HasPrefix(payload, iccHeader):
+		return writeSegment(w, marker, payload)
+	case marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader):
+		exif := payload[len(exifHeader):]
+		s.keepEXIF(exif)
+		if value := orientation(exif); value > 1 {
+			return writeSegment(w, marker, append(append([]byte(nil), exifHeader...), orientationEXIF(value)...))
+		}
+	}
+	return nil
+}
+
+func writeSegment(w io.Writer, marker byte, payload []byte) error {
+	header := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func isRestart(marker byte) bool {
+	return marker >= 0xD0 && marker <= 0xD7
+}
+
+// readMarker reads the next marker, skipping fill bytes.
+func readMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, malformed(err)
+	}
+	if b != 0xFF {
+		return 0, invalid(ReasonMalformed, "expected JPEG marker, got 0x%02x", b)
+	}
+	return readMarkerCode(r)
+}
+
+// readMarkerCode reads the code of a marker whose 0xFF was already consumed.
+func readMarkerCode(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, malformed(err)
+		}
+		if b != 0xFF {
+			return b, nil
+		}
+	}
+}
+
+// nextMarker reads the marker following the segment of marker. A scan is
+// ended by a marker whose 0xFF copyScan already consumed.
+func nextMarker(r *bufio.Reader, marker byte) (byte, error) {
+	if marker == markerSOS || isRestart(marker) {
+		return readMarkerCode(r)
+	}
+	return readMarker(r)
+}
+
+// copyScan copies entropy coded data up to the 0xFF of the marker that ends
+// it. Stuffed zero bytes and restart markers belong to the scan.
+func copyScan(w io.Writer, r *bufio.Reader) error {
+	for {
+		chunk, err := r.ReadSlice(0xFF)
+		if err == bufio.ErrBufferFull {
+			if _, err := w.Write(chunk); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return malformed(err)
+		}
+		if _, err := w.Write(chunk[:len(chunk)-1]); err != nil {
+			return err
+		}
+		next, err := r.Peek(1)
+		if err != nil {
+			return malformed(err)
+		}
+		if next[0] != 0x00 {
+			return nil
+		}
+		r.Discard(1)
+		if _, err := w.Write([]byte{0xFF, 0x00}); err != nil {
+			return err
+		}
+	}
+}
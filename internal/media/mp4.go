@@ -0,0 +1,142 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// maxBoxDepth bounds how deep nested MP4 boxes are walked.
+const maxBoxDepth = 8
+
+// containerBoxes are the MP4 boxes made of other boxes, their children have
+// to fill them exactly.
+var containerBoxes = map[string]bool{
+	"dinf": true,
+	"edts": true,
+	"mdia": true,
+	"minf": true,
+	"moof": true,
+	"moov": true,
+	"mvex": true,
+	"stbl": true,
+	"traf": true,
+	"trak": true,
+}
+
+// requiredBoxes are the children a container cannot be played without.
+var requiredBoxes = map[string][]string{
+	"moov": {"mvhd", "trak"},
+	"trak": {"tkhd", "mdia"},
+}
+
+// checkMP4 walks the boxes of an MP4 file. It has to start with ftyp, hold a
+// movie header and end with its last box.
+func checkMP4(r *bufio.Reader) error {
+	boxes, err := walkBoxes(r, -1, 0)
+	if err != nil {
+		return err
+	}
+	if len(boxes) == 0 || boxes[0] != "ftyp" {
+		return invalid(ReasonMalformed, "MP4 does not start with ftyp")
+	}
+	return requireBoxes("file", boxes, "moov")
+}
+
+// walkBoxes reads boxes until length bytes were read, or until the end of the
+// file when length is negative, and returns their types in order.
+func walkBoxes(r *bufio.Reader, length int64, depth int) ([]string, error) {
+	var boxes []string
+	for length != 0 {
+		if length < 0 {
+			if _, err := r.Peek(1); err == io.EOF {
+				return boxes, nil
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		var header [16]byte
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, malformed(err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:8])
+		headerSize := int64(8)
+		if !isBoxType(kind) {
+			return nil, invalid(ReasonMalformed, "MP4 box type %q", kind)
+		}
+		switch size {
+		case 0:
+			// The box extends to the end of the file.
+			if length >= 0 {
+				return nil, invalid(ReasonMalformed, "MP4 box %q without size inside another box", kind)
+			}
+			if _, err := io.Copy(io.Discard, r); err != nil {
+				return nil, err
+			}
+			return append(boxes, kind), nil
+		case 1:
+			if _, err := io.ReadFull(r, header[8:]); err != nil {
+				return nil, malformed(err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return nil, invalid(ReasonMalformed, "MP4 box %q of %d bytes", kind, size)
+		}
+		if length >= 0 && size > length {
+			return nil, invalid(ReasonMalformed, "MP4 box %q larger than its parent", kind)
+		}
+
+		payload := size - headerSize
+		switch {
+		case containerBoxes[kind] && depth < maxBoxDepth:
+			children, err := walkBoxes(r, payload, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := requireBoxes(kind, children, requiredBoxes[kind]...); err != nil {
+				return nil, err
+			}
+		case kind == "ftyp" && payload < 8:
+			return nil, invalid(ReasonMalformed, "MP4 ftyp of %d bytes", size)
+		default:
+			if _, err := io.CopyN(io.Discard, r, payload); err != nil {
+				return nil, malformed(err)
+			}
+		}
+		boxes = append(boxes, kind)
+		if length > 0 {
+			length -= size
+		}
+	}
+	return boxes, nil
+}
+
+func requireBoxes(parent string, boxes []string, required ...string) error {
+	for _, kind := range required {
+		found := false
+		for _, box := range boxes {
+			if box == kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return invalid(ReasonMalformed, "MP4 %s without %s", parent, kind)
+		}
+	}
+	return nil
+}
+
+// isBoxType reports whether kind is a printable four character code.
+func isBoxType(kind string) bool {
+	for i := 0; i < len(kind); i++ {
+		if kind[i] < 0x20 || kind[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngKeep are the ancillary chunks that describe how to render the image.
+// Text, time and EXIF chunks and any unknown chunks are dropped.
+var pngKeep = map[string]bool{
+	"tRNS": true, "cHRM": true, "gAMA": true, "iCCP": true, "sBIT": true,
+	"sRGB": true, "cICP": true, "mDCv": true, "cLLi": true, "bKGD": true,
+	"hIST": true, "pHYs": true, "sPLT": true, "acTL": true, "fcTL": true,
+	"fdAT": true,
+}
+
+// png copies the chunks of a PNG image, keeping critical chunks and the
+// rendering related ancillary ones. An eXIf chunk is replaced by one with the
+// orientation only.
+func (s *Sanitizer) png(w io.Writer, r io.Reader) error {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil {
+		return malformed(err)
+	}
+	if !bytes.Equal(signature, pngSignature) {
+		return invalid(ReasonMalformed, "missing PNG signature")
+	}
+	if _, err := w.Write(signature); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return malformed(err)
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:])
+		if length > 1<<31-1 {
+			return invalid(ReasonMalformed, "PNG chunk length %d", length)
+		}
+
+		switch {
+		case kind == "eXIf" && length <= maxMetadataSize && !s.passthrough:
+			exif := make([]byte, length)
+			if _, err := io.ReadFull(r, exif); err != nil {
+				return malformed(err)
+			}
+			if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+				return malformed(err)
+			}
+			s.keepEXIF(exif)
+			if value := orientation(exif); value > 1 {
+				if err := writeChunk(w, kind, orientationEXIF(value)); err != nil {
+					return err
+				}
+			}
+		case isCritical(kind) || pngKeep[kind] || s.passthrough:
+			if _, err := w.Write(header[:]); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(w, r, length+4); err != nil {
+				return malformed(err)
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+				return malformed(err)
+			}
+		}
+
+		if kind == "IEND" {
+			// Anything appended after the image is dropped.
+			return nil
+		}
+	}
+}
+
+// isCritical reports chunks a decoder needs, their type starts upper case.
+func isCritical(kind string) bool {
+	return kind[0] >= 'A' && kind[0] <= 'Z'
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
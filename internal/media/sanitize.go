@@ -0,0 +1,75 @@
+// Package media validates uploaded media and removes privacy sensitive
+// metadata from images.
+package media
+
+import (
+	"io"
+)
+
+// maxMetadataSize bounds the EXIF kept in memory for the owner.
+const maxMetadataSize = 1 << 20
+
+// Sanitizer streams an image with its metadata removed. The orientation is
+// retained in a minimal EXIF block and color profiles are left untouched.
+// Location, device and other descriptive metadata are dropped, as is anything
+// appended after the end of the image.
+type Sanitizer struct {
+	reader *io.PipeReader
+	done   chan struct{}
+	keep   bool
+	exif   []byte
+	// passthrough keeps every segment and chunk, only the structure is walked.
+	passthrough bool
+}
+
+// Supports reports whether images of the file extension can be sanitized.
+func Supports(format string) bool {
+	return format == ".jpeg" || format == ".png"
+}
+
+// Sanitize starts sanitizing src, an image with the file extension format.
+// With keep set the original EXIF is collected and available from Metadata
+// once the sanitizer was read to the end. Close must be called when done.
+func Sanitize(src io.Reader, format string, keep bool) *Sanitizer {
+	reader, writer := io.Pipe()
+	s := &Sanitizer{reader: reader, done: make(chan struct{}), keep: keep}
+	go func() {
+		defer close(s.done)
+		var err error
+		switch format {
+		case ".jpeg":
+			err = s.jpeg(writer, src)
+		case ".png":
+			err = s.png(writer, src)
+		default:
+			_, err = io.Copy(writer, src)
+		}
+		writer.CloseWithError(err)
+	}()
+	return s
+}
+
+func (s *Sanitizer) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+// Close stops sanitizing and waits until the source is no longer read, so
+// the caller may close it afterwards. A pending write fails, a pending read of
+// the source is waited for.
+func (s *Sanitizer) Close() error {
+	s.reader.Close()
+	<-s.done
+	return nil
+}
+
+// Metadata returns the original EXIF when it was asked to be kept.
+func (s *Sanitizer) Metadata() []byte {
+	return s.exif
+}
+
+// keepEXIF remembers the removed EXIF for the owner.
+func (s *Sanitizer) keepEXIF(exif []byte) {
+	if s.keep && s.exif == nil && len(exif) <= maxMetadataSize {
+		s.exif = append([]byte(nil), exif...)
+	}
+}
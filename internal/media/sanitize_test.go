@@ -0,0 +1,268 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// fuzzMaxPixels keeps fuzzed images small enough to decode quickly.
+const fuzzMaxPixels = 1 << 16
+
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7)
+	}
+	return img
+}
+
+func encodePNG(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t testing.TB, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func appendBytes(data []byte, extra ...byte) []byte {
+	return append(append([]byte{}, data...), extra...)
+}
+
+// insertAt returns data with parts inserted at offset.
+func insertAt(data []byte, offset int, parts ...[]byte) []byte {
+	out := append([]byte{}, data[:offset]...)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return append(out, data[offset:]...)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	writeSegment(&buf, marker, payload)
+	return buf.Bytes()
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	var buf bytes.Buffer
+	writeChunk(&buf, kind, data)
+	return buf.Bytes()
+}
+
+// testEXIF builds an EXIF block with the orientation and a GPS IFD holding
+// the latitude.
+func testEXIF(value uint16) []byte {
+	order := binary.BigEndian
+	exif := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	exif = order.AppendUint16(exif, 2)
+	exif = order.AppendUint16(exif, tagOrientation)
+	exif = order.AppendUint16(exif, typeShort)
+	exif = order.AppendUint32(exif, 1)
+	exif = order.AppendUint32(exif, uint32(value)<<16)
+	// GPSInfo points at the GPS IFD right after IFD0.
+	exif = order.AppendUint16(exif, 0x8825)
+	exif = order.AppendUint16(exif, 4)
+	exif = order.AppendUint32(exif, 1)
+	exif = order.AppendUint32(exif, 38)
+	exif = order.AppendUint32(exif, 0)
+	// GPSLatitude as three rationals stored after the GPS IFD.
+	exif = order.AppendUint16(exif, 1)
+	exif = order.AppendUint16(exif, 0x0002)
+	exif = order.AppendUint16(exif, 5)
+	exif = order.AppendUint32(exif, 3)
+	exif = order.AppendUint32(exif, 56)
+	exif = order.AppendUint32(exif, 0)
+	for _, part := range []uint32{52, 31, 12} {
+		exif = order.AppendUint32(exif, part)
+		exif = order.AppendUint32(exif, 1)
+	}
+	return exif
+}
+
+func exifSegment(exif []byte) []byte {
+	return jpegSegment(markerAPP1, append(append([]byte{}, exifHeader...), exif...))
+}
+
+func TestSanitize(t *testing.T) {
+	plainJPEG := encodeJPEG(t, testImage(32, 24))
+	plainPNG := encodePNG(t, testImage(32, 24))
+	// The image header chunk of a PNG ends 33 bytes in.
+	const afterIHDR = 33
+
+	exif := testEXIF(6)
+	uprightEXIF := testEXIF(1)
+	gps := exif[38:]
+	xmp := jpegSegment(markerAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>Berlin</x:xmpmeta>"))
+	iptc := jpegSegment(0xED, []byte("Photoshop 3.0\x008BIM\x04\x04\x00\x00\x00\x00\x00\x08\x1c\x02\x5a\x00\x04Bonn"))
+	comment := jpegSegment(markerCOM, []byte("shot by Jane Doe"))
+	icc := jpegSegment(markerAPP2, append(append([]byte{}, iccHeader...), "\x01\x01test profile"...))
+	iccp := pngChunk("iCCP", []byte("test\x00\x00compressed profile"))
+	text := pngChunk("tEXt", []byte("Author\x00Jane Doe"))
+	ztxt := pngChunk("zTXt", []byte("Comment\x00\x00compressed comment"))
+	itxt := pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta>Berlin</x:xmpmeta>"))
+
+	jpegData := insertAt(plainJPEG, 2, exifSegment(exif), xmp, iptc, comment, icc)
+	pngData := insertAt(plainPNG, afterIHDR, iccp, text, ztxt, itxt, pngChunk("eXIf", exif))
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		keep   bool
+		// removed must not be in the sanitized image, kept must be.
+		removed  [][]byte
+		kept     [][]byte
+		metadata []byte
+	}{
+		{
+			name:    "jpeg",
+			format:  ".jpeg",
+			data:    jpegData,
+			removed: [][]byte{gps, xmp, iptc, comment},
+			kept:    [][]byte{icc, exifSegment(orientationEXIF(6))},
+		},
+		{
+			name:     "jpeg keep metadata",
+			format:   ".jpeg",
+			data:     jpegData,
+			keep:     true,
+			removed:  [][]byte{gps, xmp, iptc, comment},
+			kept:     [][]byte{icc, exifSegment(orientationEXIF(6))},
+			metadata: exif,
+		},
+		{
+			name:     "jpeg upright",
+			format:   ".jpeg",
+			data:     insertAt(plainJPEG, 2, exifSegment(uprightEXIF)),
+			keep:     true,
+			removed:  [][]byte{exifHeader},
+			metadata: uprightEXIF,
+		},
+		{
+			name:    "png",
+			format:  ".png",
+			data:    pngData,
+			removed: [][]byte{gps, text, ztxt, itxt},
+			kept:    [][]byte{iccp, pngChunk("eXIf", orientationEXIF(6))},
+		},
+		{
+			name:     "png keep metadata",
+			format:   ".png",
+			data:     pngData,
+			keep:     true,
+			removed:  [][]byte{gps, text, ztxt, itxt},
+			kept:     [][]byte{iccp, pngChunk("eXIf", orientationEXIF(6))},
+			metadata: exif,
+		},
+		{
+			name:     "png upright",
+			format:   ".png",
+			data:     insertAt(plainPNG, afterIHDR, pngChunk("eXIf", uprightEXIF)),
+			keep:     true,
+			removed:  [][]byte{[]byte("eXIf")},
+			metadata: uprightEXIF,
+		},
+		{
+			name:    "jpeg trailing data",
+			format:  ".jpeg",
+			data:    appendBytes(plainJPEG, []byte("motion photo")...),
+			removed: [][]byte{[]byte("motion photo")},
+		},
+		{
+			name:    "png trailing data",
+			format:  ".png",
+			data:    appendBytes(plainPNG, []byte("<?php")...),
+			removed: [][]byte{[]byte("<?php")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sanitizer := Sanitize(bytes.NewReader(test.data), test.format, test.keep)
+			out, err := io.ReadAll(sanitizer)
+			sanitizer.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, part := range test.removed {
+				if bytes.Contains(out, part) {
+					t.Errorf("sanitized image still contains %q", part)
+				}
+			}
+			for _, part := range test.kept {
+				if !bytes.Contains(out, part) {
+					t.Errorf("sanitized image lost %q", part)
+				}
+			}
+			if !bytes.Equal(sanitizer.Metadata(), test.metadata) {
+				t.Errorf("got metadata %x, want %x", sanitizer.Metadata(), test.metadata)
+			}
+			img, _, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("sanitized image does not decode: %v", err)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(32, 24) {
+				t.Fatalf("sanitized image is %v, want 32x24", size)
+			}
+		})
+	}
+}
+
+// fuzzSanitize checks that sanitizing never panics and that an image which
+// decoded before still decodes, to the same size, afterwards.
+func fuzzSanitize(f *testing.F, format string, seeds [][]byte) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sanitizer := Sanitize(bytes.NewReader(data), format, true)
+		out, err := io.ReadAll(sanitizer)
+		sanitizer.Close()
+		if err != nil {
+			if !IsInvalid(err) {
+				t.Fatalf("not a validation error: %v", err)
+			}
+			return
+		}
+		if len(sanitizer.Metadata()) > maxMetadataSize {
+			t.Fatalf("kept %d bytes of metadata", len(sanitizer.Metadata()))
+		}
+
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || int64(config.Width)*int64(config.Height) > fuzzMaxPixels {
+			return
+		}
+		if _, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return
+		}
+		sanitized, _, err := image.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("sanitized image does not decode: %v", err)
+		}
+		if size := sanitized.Bounds().Size(); size.X != config.Width || size.Y != config.Height {
+			t.Fatalf("sanitized image is %v, want %dx%d", size, config.Width, config.Height)
+		}
+	})
+}
+
+func FuzzSanitizeJPEG(f *testing.F) {
+	plain := encodeJPEG(f, testImage(16, 16))
+	withEXIF := insertAt(plain, 2, exifSegment(testEXIF(6)))
+	fuzzSanitize(f, ".jpeg", [][]byte{plain, withEXIF, appendBytes(plain, []byte("trailer")...)})
+}
+
+func FuzzSanitizePNG(f *testing.F) {
+	fuzzSanitize(f, ".png", [][]byte{encodePNG(f, testImage(16, 16)), encodePNG(f, image.NewGray16(image.Rect(0, 0, 4, 4)))})
+}
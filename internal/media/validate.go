@@ -0,0 +1,215 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+)
+
+// maxHeaderSize bounds what is read to learn the size of an image before
+// decoding it.
+const maxHeaderSize = 4 << 20
+
+// Validator checks media while it streams to storage. Images are fully
+// decoded within a pixel limit, videos have their container structure walked
+// and anything after the end of the media is refused. The verdict is returned
+// by Read in place of io.EOF, so nothing is stored unless the whole file
+// passed.
+type Validator struct {
+	src     io.Reader
+	writer  *io.PipeWriter
+	done    chan error
+	stop    chan struct{}
+	once    sync.Once
+	verdict error
+	err     error
+}
+
+// Validate starts checking src, media with the file extension format, within
+// limits. Close must be called when done.
+func Validate(src io.Reader, format string, limits Limits) *Validator {
+	reader, writer := io.Pipe()
+	v := &Validator{src: src, writer: writer, done: make(chan error, 1), stop: make(chan struct{})}
+	go func() {
+		err := check(bufio.NewReader(reader), format, limits, v.stop)
+		reader.CloseWithError(err)
+		v.done <- err
+	}()
+	return v
+}
+
+func (v *Validator) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.src.Read(p)
+	if n > 0 {
+		if _, werr := v.writer.Write(p[:n]); werr != nil {
+			// The check ended early, which only happens when it failed.
+			v.err = v.wait()
+			if v.err == nil {
+				v.err = werr
+			}
+			return 0, v.err
+		}
+	}
+	switch {
+	case err == io.EOF:
+		v.writer.Close()
+		v.err = v.wait()
+		if v.err == nil {
+			v.err = io.EOF
+		}
+		return n, v.err
+	case err != nil:
+		v.writer.CloseWithError(err)
+		v.wait()
+		v.err = err
+	}
+	return n, err
+}
+
+// Close stops the check if the media was not read to the end. It may be
+// called while a read is pending.
+func (v *Validator) Close() error {
+	v.writer.CloseWithError(io.ErrClosedPipe)
+	select {
+	case <-v.stop:
+	default:
+		close(v.stop)
+	}
+	v.wait()
+	return nil
+}
+
+// Metadata passes on the EXIF kept by a sanitizer the media was read from.
+func (v *Validator) Metadata() []byte {
+	if source, ok := v.src.(interface{ Metadata() []byte }); ok {
+		return source.Metadata()
+	}
+	return nil
+}
+
+// wait returns the verdict of the check once it finished.
+func (v *Validator) wait() error {
+	v.once.Do(func() { v.verdict = <-v.done })
+	return v.verdict
+}
+
+func check(r *bufio.Reader, format string, limits Limits, stop <-chan struct{}) error {
+	var err error
+	switch format {
+	case ".jpeg", ".png":
+		err = checkImage(r, format, limits, stop)
+	case ".gif":
+		err = checkGIF(r, limits.MaxPixels)
+	case ".mp4":
+		err = checkMP4(r)
+	case ".webm":
+		err = checkWebM(r)
+	}
+	if err != nil {
+		return err
+	}
+	trailing, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return err
+	}
+	if trailing > 0 {
+		return invalid(ReasonTrailingData, "%d bytes after the end of the media", trailing)
+	}
+	return nil
+}
+
+// checkImage walks the structure of a JPEG or PNG image to find where it ends
+// and decodes what the walk passes on.
+func checkImage(r *bufio.Reader, format string, limits Limits, stop <-chan struct{}) error {
+	reader, writer := io.Pipe()
+	decoded := make(chan error, 1)
+	go func() {
+		err := decodeImage(reader, format, limits, stop)
+		if err == nil {
+			_, err = io.Copy(io.Discard, reader)
+		}
+		reader.CloseWithError(err)
+		decoded <- err
+	}()
+
+	walker := &Sanitizer{passthrough: true}
+	var err error
+	if format == ".jpeg" {
+		err = walker.jpeg(writer, r)
+	} else {
+		err = walker.png(writer, r)
+	}
+	writer.CloseWithError(err)
+	decodeErr := <-decoded
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// decodeImage checks the dimensions of the image before decoding all of it,
+// so decompression bombs are refused before their pixels are allocated. The
+// decode then waits for its share of the memory budget.
+func decodeImage(r io.Reader, format string, limits Limits, stop <-chan struct{}) error {
+	header := &cappedBuffer{limit: maxHeaderSize}
+	tee := io.TeeReader(r, header)
+	var config image.Config
+	var err error
+	if format == ".jpeg" {
+		config, err = jpeg.DecodeConfig(tee)
+	} else {
+		config, err = png.DecodeConfig(tee)
+	}
+	if err != nil {
+		return decodeError(err)
+	}
+	pixels := int64(config.Width) * int64(config.Height)
+	if pixels > limits.MaxPixels {
+		return invalid(ReasonTooManyPixels, "%dx%d is more than %d pixels", config.Width, config.Height, limits.MaxPixels)
+	}
+	reserved, err := limits.Memory.acquire(pixels*decodeBytesPerPixel, stop)
+	if err != nil {
+		return err
+	}
+	defer limits.Memory.release(reserved)
+
+	full := io.MultiReader(bytes.NewReader(header.Bytes()), r)
+	if format == ".jpeg" {
+		_, err = jpeg.Decode(full)
+	} else {
+		_, err = png.Decode(full)
+	}
+	return decodeError(err)
+}
+
+// decodeError classifies an error of the image decoders. The structure walk
+// already reported problems of the source, so the rest is about the content.
+func decodeError(err error) error {
+	if err == nil || IsInvalid(err) {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return invalid(ReasonMalformed, "%s", err)
+}
+
+// cappedBuffer is a buffer that refuses to grow past limit.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, invalid(ReasonMalformed, "image header larger than %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,198 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func encodeGIF(t testing.TB, frames int) []byte {
+	frame := image.NewPaletted(image.Rect(0, 0, 30, 20), color.Palette{color.Black, color.White})
+	animation := &gif.GIF{}
+	for range frames {
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 1)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func box(kind string, children ...[]byte) []byte {
+	payload := bytes.Join(children, nil)
+	header := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(header, uint32(8+len(payload)))
+	copy(header[4:], kind)
+	return append(header, payload...)
+}
+
+func testMP4() []byte {
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2")),
+		box("moov",
+			box("mvhd", make([]byte, 100)),
+			box("trak",
+				box("tkhd", make([]byte, 84)),
+				box("mdia", box("mdhd", make([]byte, 24)), box("minf", box("stbl", box("stsd", make([]byte, 8))))))),
+		box("mdat", make([]byte, 64)),
+	}, nil)
+}
+
+// element encodes an EBML element with an eight byte size.
+func element(id []byte, children ...[]byte) []byte {
+	payload := bytes.Join(children, nil)
+	out := append([]byte{}, id...)
+	out = append(out, 0x01, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(out[len(out)-4:], uint32(len(payload)))
+	return append(out, payload...)
+}
+
+var (
+	idEBML     = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	idDocType  = []byte{0x42, 0x82}
+	idSegment  = []byte{0x18, 0x53, 0x80, 0x67}
+	idInfo     = []byte{0x15, 0x49, 0xA9, 0x66}
+	idTracks   = []byte{0x16, 0x54, 0xAE, 0x6B}
+	idCluster  = []byte{0x1F, 0x43, 0xB6, 0x75}
+	idSimple   = []byte{0xA3}
+	webmHeader = element(idEBML, element([]byte{0x42, 0x86}, []byte{1}), element(idDocType, []byte("webm")))
+)
+
+func testWebM() []byte {
+	segment := element(idSegment,
+		element(idInfo, []byte{1, 2}),
+		element(idTracks, []byte{3}),
+		element(idCluster, element(idSimple, []byte{1, 2, 3})))
+	return append(append([]byte{}, webmHeader...), segment...)
+}
+
+// testLiveWebM has a segment and a cluster of unknown size, as written by
+// recorders that cannot seek back.
+func testLiveWebM() []byte {
+	data := append([]byte{}, webmHeader...)
+	data = append(data, idSegment...)
+	data = append(data, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	data = append(data, element(idTracks, []byte{3})...)
+	data = append(data, idCluster...)
+	data = append(data, 0xFF)
+	return append(data, element(idSimple, []byte{1, 2, 3})...)
+}
+
+func TestValidate(t *testing.T) {
+	pngData := encodePNG(t, testImage(64, 48))
+	jpegData := encodeJPEG(t, testImage(64, 48))
+	gifData := encodeGIF(t, 3)
+	mp4Data := testMP4()
+	webmData := testWebM()
+	corruptPNG := appendBytes(pngData)
+	corruptPNG[len(corruptPNG)-20] ^= 0xFF
+
+	tests := []struct {
+		name      string
+		format    string
+		data      []byte
+		maxPixels int64
+		want      string
+	}{
+		{name: "png", format: ".png", data: pngData},
+		{name: "jpeg", format: ".jpeg", data: jpegData},
+		{name: "gif", format: ".gif", data: gifData},
+		{name: "mp4", format: ".mp4", data: mp4Data},
+		{name: "webm", format: ".webm", data: webmData},
+		{name: "live webm", format: ".webm", data: testLiveWebM()},
+		{name: "png trailing data", format: ".png", data: appendBytes(pngData, []byte("<?php")...), want: ReasonTrailingData},
+		{name: "jpeg trailing data", format: ".jpeg", data: appendBytes(jpegData, []byte("PK")...), want: ReasonTrailingData},
+		{name: "gif trailing data", format: ".gif", data: appendBytes(gifData, 0), want: ReasonTrailingData},
+		{name: "webm trailing data", format: ".webm", data: appendBytes(webmData, 0), want: ReasonTrailingData},
+		{name: "png truncated", format: ".png", data: pngData[:len(pngData)-20], want: ReasonTruncated},
+		{name: "jpeg truncated", format: ".jpeg", data: jpegData[:len(jpegData)/2], want: ReasonTruncated},
+		{name: "gif truncated", format: ".gif", data: gifData[:len(gifData)-5], want: ReasonTruncated},
+		{name: "mp4 truncated", format: ".mp4", data: mp4Data[:len(mp4Data)-10], want: ReasonTruncated},
+		{name: "webm truncated", format: ".webm", data: webmData[:len(webmData)-2], want: ReasonTruncated},
+		{name: "png checksum", format: ".png", data: corruptPNG, want: ReasonMalformed},
+		{name: "png too many pixels", format: ".png", data: pngData, maxPixels: 64*48 - 1, want: ReasonTooManyPixels},
+		{name: "gif frames exceed budget", format: ".gif", data: encodeGIF(t, 20), maxPixels: 30 * 20, want: ReasonTooManyPixels},
+		{name: "mp4 without ftyp", format: ".mp4", data: box("moov", box("mvhd")), want: ReasonMalformed},
+		{name: "mp4 without moov", format: ".mp4", data: box("ftyp", []byte("isom\x00\x00\x02\x00")), want: ReasonMalformed},
+		{name: "matroska is not webm", format: ".webm", data: bytes.Replace(webmData, []byte("webm"), []byte("mkvx"), 1), want: ReasonMalformed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			maxPixels := test.maxPixels
+			if maxPixels == 0 {
+				maxPixels = 1 << 20
+			}
+			// Reading a byte at a time must give the same verdict.
+			for _, oneByte := range []bool{false, true} {
+				var src io.Reader = bytes.NewReader(test.data)
+				if oneByte {
+					src = iotest.OneByteReader(src)
+				}
+				validator := Validate(src, test.format, Limits{MaxPixels: maxPixels, Memory: NewBudget(1 << 30)})
+				out, err := io.ReadAll(validator)
+				validator.Close()
+
+				got := ""
+				var invalid *ValidationError
+				if errors.As(err, &invalid) {
+					got = invalid.Reason
+				} else if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if got != test.want {
+					t.Fatalf("got reason %q (%v), want %q", got, err, test.want)
+				}
+				if err == nil && !bytes.Equal(out, test.data) {
+					t.Fatal("validated media differs from the source")
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePassesSourceErrors(t *testing.T) {
+	failure := errors.New("body read failed")
+	src := io.MultiReader(bytes.NewReader(encodePNG(t, testImage(8, 8))[:40]), iotest.ErrReader(failure))
+	validator := Validate(src, ".png", Limits{MaxPixels: 1 << 20})
+	defer validator.Close()
+	if _, err := io.ReadAll(validator); err != failure {
+		t.Fatalf("got %v, want the source error", err)
+	}
+}
+
+// fuzzCheck runs a structure check and accepts only validation errors, any
+// other error or a panic is a bug.
+func fuzzCheck(f *testing.F, seeds [][]byte, check func(*bufio.Reader) error) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := check(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil && !IsInvalid(err) {
+			t.Fatalf("not a validation error: %v", err)
+		}
+	})
+}
+
+func FuzzCheckMP4(f *testing.F) {
+	fuzzCheck(f, [][]byte{testMP4(), box("ftyp", []byte("isom\x00\x00\x02\x00")), {0, 0, 0, 1, 'f', 't', 'y', 'p'}}, checkMP4)
+}
+
+func FuzzCheckWebM(f *testing.F) {
+	fuzzCheck(f, [][]byte{testWebM(), testLiveWebM(), webmHeader}, checkWebM)
+}
+
+func FuzzCheckGIF(f *testing.F) {
+	fuzzCheck(f, [][]byte{encodeGIF(f, 1), encodeGIF(f, 3)}, func(r *bufio.Reader) error {
+		return checkGIF(r, fuzzMaxPixels)
+	})
+}
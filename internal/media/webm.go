@@ -0,0 +1,155 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/bits"
+)
+
+// EBML element IDs, with their length marker bits.
+const (
+	ebmlHeaderID  = 0x1A45DFA3
+	ebmlDocTypeID = 0x4282
+	segmentID     = 0x18538067
+	tracksID      = 0x1654AE6B
+	clusterID     = 0x1F43B675
+)
+
+// maxEBMLHeaderSize bounds the EBML header, which is read into memory.
+const maxEBMLHeaderSize = 4096
+
+// checkWebM walks the EBML structure of a WebM file: the header has to name
+// the webm document type and the segment has to hold tracks and clusters.
+// Only clusters, which are written while recording, may have an unknown size.
+func checkWebM(r *bufio.Reader) error {
+	id, size, unknown, _, err := readElementHeader(r)
+	if err != nil {
+		return err
+	}
+	if id != ebmlHeaderID || unknown || size > maxEBMLHeaderSize {
+		return invalid(ReasonMalformed, "missing EBML header")
+	}
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return malformed(err)
+	}
+	docType, err := ebmlDocType(header)
+	if err != nil {
+		return err
+	}
+	if docType != "webm" {
+		return invalid(ReasonMalformed, "EBML document type %q", docType)
+	}
+
+	id, remaining, unknown, _, err := readElementHeader(r)
+	if err != nil {
+		return err
+	}
+	if id != segmentID {
+		return invalid(ReasonMalformed, "missing WebM segment")
+	}
+	if unknown {
+		remaining = -1
+	}
+	var tracks, clusters bool
+	for remaining != 0 {
+		if remaining < 0 {
+			if _, err := r.Peek(1); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		id, size, unknown, consumed, err := readElementHeader(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case unknown && id == clusterID:
+			// The children of the cluster follow and are walked as siblings.
+		case unknown:
+			return invalid(ReasonMalformed, "WebM element 0x%X of unknown size", id)
+		default:
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return malformed(err)
+			}
+			consumed += size
+		}
+		tracks = tracks || id == tracksID
+		clusters = clusters || id == clusterID
+		if remaining >= 0 {
+			if consumed > remaining {
+				return invalid(ReasonMalformed, "WebM element 0x%X larger than the segment", id)
+			}
+			remaining -= consumed
+		}
+	}
+	if !tracks || !clusters {
+		return invalid(ReasonMalformed, "WebM segment without tracks or clusters")
+	}
+	return nil
+}
+
+// ebmlDocType finds the document type in the body of the EBML header.
+func ebmlDocType(header []byte) (string, error) {
+	r := bufio.NewReader(bytes.NewReader(header))
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			return "", invalid(ReasonMalformed, "EBML header without document type")
+		}
+		id, size, unknown, _, err := readElementHeader(r)
+		if err != nil {
+			return "", invalid(ReasonMalformed, "EBML header")
+		}
+		if unknown || size > int64(r.Buffered()) {
+			return "", invalid(ReasonMalformed, "EBML header element 0x%X", id)
+		}
+		value := make([]byte, size)
+		io.ReadFull(r, value)
+		if id == ebmlDocTypeID {
+			return string(bytes.TrimRight(value, "\x00")), nil
+		}
+	}
+}
+
+// readElementHeader reads the ID and the size of an EBML element and how many
+// bytes they took. Sizes with all value bits set are unknown.
+func readElementHeader(r *bufio.Reader) (id uint32, size int64, unknown bool, length int64, err error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, false, 0, malformed(err)
+	}
+	idLength := bits.LeadingZeros8(first) + 1
+	if idLength > 4 {
+		return 0, 0, false, 0, invalid(ReasonMalformed, "EBML element ID 0x%02X", first)
+	}
+	id = uint32(first)
+	for i := 1; i < idLength; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, false, 0, malformed(err)
+		}
+		id = id<<8 | uint32(b)
+	}
+
+	first, err = r.ReadByte()
+	if err != nil {
+		return 0, 0, false, 0, malformed(err)
+	}
+	sizeLength := bits.LeadingZeros8(first) + 1
+	if sizeLength > 8 {
+		return 0, 0, false, 0, invalid(ReasonMalformed, "EBML size of element 0x%X", id)
+	}
+	value := uint64(first) & (0xFF >> sizeLength)
+	unknown = value == 0xFF>>sizeLength
+	for i := 1; i < sizeLength; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, false, 0, malformed(err)
+		}
+		value = value<<8 | uint64(b)
+		unknown = unknown && b == 0xFF
+	}
+	return id, int64(value), unknown, int64(idLength + sizeLength), nil
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Upload rejection reasons.
+const (
+	RejectTooLarge    = "too_large"
+	RejectInvalidForm = "invalid_form"
+	RejectMissingFile = "missing_file"
+	RejectEmptyFile   = "empty_file"
+	RejectFormat      = "format"
+	RejectQuota       = "quota"
+)
+
+// Login outcomes.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginBlocked = "blocked"
+)
+
+// Business records application level signals: uploads and logins.
+type Business struct {
+	uploadBytes      *prometheus.HistogramVec
+	uploadRejections *prometheus.CounterVec
+	logins           *prometheus.CounterVec
+}
+
+func NewBusiness(registry *prometheus.Registry) *Business {
+	factory := promauto.With(registry)
+	return &Business{
+		uploadBytes: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "uploads",
+				Name:      "bytes",
+				Help:      "Size of accepted uploads in bytes, partitioned by format.",
+				Buckets:   prometheus.ExponentialBuckets(16*1024, 4, 8),
+			},
+			[]string{"format"},
+		),
+		uploadRejections: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "uploads",
+				Name:      "rejections_total",
+				Help:      "Total number of rejected uploads, partitioned by reason.",
+			},
+			[]string{"reason"},
+		),
+		logins: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "auth",
+				Name:      "logins_total",
+				Help:      "Total number of login attempts, partitioned by outcome.",
+			},
+			[]string{"outcome"},
+		),
+	}
+}
+
+func (b *Business) UploadAccepted(format string, size int64) {
+	b.uploadBytes.WithLabelValues(format).Observe(float64(size))
+}
+
+func (b *Business) UploadRejected(reason string) {
+	b.uploadRejections.WithLabelValues(reason).Inc()
+}
+
+func (b *Business) Login(outcome string) {
+	b.logins.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type JobMetrics struct {
+	runsTotal      *prometheus.CounterVec
+	runDuration    *prometheus.HistogramVec
+	processedTotal *prometheus.CounterVec
+	leader         prometheus.Gauge
+	queueDepth     *prometheus.GaugeVec
+	queueLatency   *prometheus.GaugeVec
+}
+
+func NewJobMetrics(registry *prometheus.Registry) *JobMetrics {
+	factory := promauto.With(registry)
+	return &JobMetrics{
+		runsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "runs_total",
+				Help:      "Total number of background job runs, partitioned by job and outcome.",
+			},
+			[]string{"job", "outcome"},
+		),
+		runDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "run_duration_seconds",
+				Help:      "Background job run durations in seconds.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"job"},
+		),
+		processedTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "processed_items_total",
+				Help:      "Total number of items processed by background jobs, e.g. purged sessions.",
+			},
+			[]string{"job"},
+		),
+		leader: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "leader",
+				Help:      "1 if this replica holds the background job leader lock.",
+			},
+		),
+		queueDepth: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "queue_depth",
+				Help:      "Number of items waiting to be processed by a background job.",
+			},
+			[]string{"job"},
+		),
+		queueLatency: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "jobs",
+				Name:      "queue_oldest_item_age_seconds",
+				Help:      "Age in seconds of the oldest item waiting to be processed by a background job.",
+			},
+			[]string{"job"},
+		),
+	}
+}
+
+func (m *JobMetrics) Observe(job string, duration time.Duration, processed int64, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.runsTotal.WithLabelValues(job, outcome).Inc()
+	m.runDuration.WithLabelValues(job).Observe(duration.Seconds())
+	m.processedTotal.WithLabelValues(job).Add(float64(processed))
+}
+
+func (m *JobMetrics) SetLeader(leader bool) {
+	if leader {
+		m.leader.Set(1)
+	} else {
+		m.leader.Set(0)
+	}
+}
+
+// SetBacklog records how much work a job has waiting and how long the oldest
+// item has been waiting for.
+func (m *JobMetrics) SetBacklog(job string, depth int64, oldest time.Duration) {
+	m.queueDepth.WithLabelValues(job).Set(float64(depth))
+	m.queueLatency.WithLabelValues(job).Set(oldest.Seconds())
+}
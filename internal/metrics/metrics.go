@@ -2,10 +2,12 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -13,17 +15,32 @@ import (
 const (
 	namespace = "image-sharing"
 	subsystem = "http"
+	// unmatchedPath labels requests no route matched.
+	unmatchedPath = "unmatched"
 )
 
+// NewRegistry returns the registry all application metrics are registered
+// on, with the Go runtime and process collectors.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
 type Metrics struct {
-	requestsTotal   *prometheus.CounterVec
-	requestDuration *prometheus.HistogramVec
+	requestsTotal    *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
 }
 
-func New() *Metrics {
+func New(registry *prometheus.Registry) *Metrics {
+	factory := promauto.With(registry)
 	m := &Metrics{
 		// HTTP metrics
-		requestsTotal: promauto.NewCounterVec(
+		requestsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Subsystem: subsystem,
@@ -32,7 +49,7 @@ func New() *Metrics {
 			},
 			[]string{"code", "method", "path"},
 		),
-		requestDuration: promauto.NewHistogramVec(
+		requestDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Subsystem: subsystem,
@@ -42,6 +59,14 @@ func New() *Metrics {
 			},
 			[]string{"method", "path"},
 		),
+		requestsInFlight: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "requests_in_flight",
+				Help:      "Number of HTTP requests currently being served.",
+			},
+		),
 	}
 	return m
 }
@@ -51,24 +76,30 @@ func (m *Metrics) Middleware() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ww := NewResponseWriter(w)
+			m.requestsInFlight.Inc()
+			defer m.requestsInFlight.Dec()
 
 			next.ServeHTTP(ww, r)
 
 			duration := time.Since(start).Seconds()
 
-			path := chi.RouteContext(r.Context()).RoutePattern()
-			if path == "" {
-				path = r.URL.Path
+			// Raw paths of unmatched requests would let clients create any
+			// number of series, they share one label instead.
+			path := unmatchedPath
+			if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+				path = routeContext.RoutePattern()
 			}
 
 			m.requestDuration.WithLabelValues(r.Method, path).Observe(duration)
-			m.requestsTotal.WithLabelValues(http.StatusText(ww.status), r.Method, path).Inc()
+			m.requestsTotal.WithLabelValues(strconv.Itoa(ww.Status()), r.Method, path).Inc()
 		})
 	}
 }
 
-func (m *Metrics) Handler() http.Handler {
-	return promhttp.Handler()
+// Handler serves the metrics of registry. A failing collector does not fail
+// the whole scrape.
+func Handler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry, ErrorHandling: promhttp.ContinueOnError})
 }
 
 type ResponseWriter struct {
@@ -104,6 +135,15 @@ func (rw *ResponseWriter) Status() int {
 	return rw.status
 }
 
+// Flush sends buffered data to the client if the wrapped writer supports it.
+func (rw *ResponseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.status = http.StatusOK
+		rw.wroteHeader = true
+	}
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
 func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
 	return rw.ResponseWriter
 }
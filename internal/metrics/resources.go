@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// collectTimeout bounds the work a single scrape can trigger.
+const collectTimeout = 5 * time.Second
+
+// storageUsageTTL keeps scrapes from walking the whole images directory every time.
+const storageUsageTTL = time.Minute
+
+// RegisterDBStats exports the connection pool statistics of db.
+func RegisterDBStats(registry *prometheus.Registry, db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// RegisterActiveSessions exports the number of sessions that are neither
+// revoked nor expired. count is called on every scrape.
+func RegisterActiveSessions(registry *prometheus.Registry, count func(ctx context.Context) (int64, error)) {
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sessions", "active"),
+		"Number of sessions that are neither revoked nor expired.",
+		nil, nil,
+	)
+	registry.MustRegister(&gaugeCollector{desc: desc, value: func(ctx context.Context) (float64, error) {
+		n, err := count(ctx)
+		return float64(n), err
+	}})
+}
+
+// RegisterStorageUsage exports the total size of the files stored in dir.
+func RegisterStorageUsage(registry *prometheus.Registry, dir string) {
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "storage", "used_bytes"),
+		"Total size in bytes of the files in the images directory.",
+		nil, nil,
+	)
+	registry.MustRegister(&gaugeCollector{desc: desc, ttl: storageUsageTTL, value: func(ctx context.Context) (float64, error) {
+		size, err := dirSize(ctx, dir)
+		return float64(size), err
+	}})
+}
+
+func dirSize(ctx context.Context, dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// gaugeCollector reports a single gauge computed at scrape time, optionally
+// reusing the last value for ttl. A failing value is reported as an invalid
+// metric so the rest of the scrape still succeeds.
+type gaugeCollector struct {
+	desc  *prometheus.Desc
+	ttl   time.Duration
+	value func(ctx context.Context) (float64, error)
+
+	mu        sync.Mutex
+	last      float64
+	collected time.Time
+}
+
+func (c *gaugeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *gaugeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl == 0 || time.Since(c.collected) >= c.ttl {
+		ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+		defer cancel()
+		value, err := c.value(ctx)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.desc, err)
+			return
+		}
+		c.last, c.collected = value, time.Now()
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, c.last)
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/repository"
+	"image-sharing/pkg/token"
+)
+
+var errInvalidAPIToken = errors.New("invalid api token")
+
+// errTokenLookup wraps storage failures so they are not reported as bad credentials.
+var errTokenLookup = errors.New("api token lookup failed")
+
+type APITokenStore interface {
+	GetAPITokenByPrefix(ctx context.Context, prefix string) (db.GetAPITokenByPrefixRow, error)
+	TouchAPIToken(ctx context.Context, id int32) error
+}
+
+func verifyAPIToken(ctx context.Context, apiTokens APITokenStore, apiToken string) (*AccessClaims, error) {
+	prefix, ok := token.ParseAPIToken(apiToken)
+	if !ok {
+		return nil, errInvalidAPIToken
+	}
+
+	stored, err := apiTokens.GetAPITokenByPrefix(ctx, prefix)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errInvalidAPIToken
+		}
+		return nil, fmt.Errorf("%w: %w", errTokenLookup, err)
+	}
+	if !token.CheckAPIToken(apiToken, stored.TokenHash) {
+		return nil, errInvalidAPIToken
+	}
+	if stored.RevokedAt.Valid {
+		return nil, errors.New("api token revoked")
+	}
+	if stored.ExpiresAt.Valid && stored.ExpiresAt.Time.Before(time.Now()) {
+		return nil, errors.New("api token expired")
+	}
+
+	if err = apiTokens.TouchAPIToken(ctx, stored.ID); err != nil {
+		logging.FromContext(ctx).Error("failed to update api token last use", "token_id", stored.ID, "error", err)
+	}
+
+	claims := &token.UserClaims{
+		ID:    stored.UserID,
+		Login: stored.Login,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: stored.Login,
+		},
+	}
+	if stored.ExpiresAt.Valid {
+		claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(stored.ExpiresAt.Time)
+	}
+	return &AccessClaims{AccesToken: apiToken, APITokenID: stored.ID, Scopes: stored.Scopes, UserClaims: claims}, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
+	"image-sharing/internal/logging"
 	"image-sharing/pkg/token"
 )
 
@@ -13,37 +15,92 @@ type AuthKey struct{}
 
 type AccessClaims struct {
 	AccesToken string
+	// APITokenID is set when the request was authenticated with a personal access token.
+	APITokenID  int32
+	Scopes      []string
+	Permissions []string
 	*token.UserClaims
 }
 
-func GetAuthMiddleware(tokenMaker *token.JWTMaker) func(http.Handler) http.Handler {
+func (c *AccessClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+func (c *AccessClaims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+type PermissionStore interface {
+	GetUserPermissions(ctx context.Context, userID int32) ([]string, error)
+}
+
+func GetAuthMiddleware(tokenMaker token.Maker, apiTokens APITokenStore, permissions PermissionStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, err := verifyClaims(r, tokenMaker)
+			claims, err := verifyClaims(r, tokenMaker, apiTokens)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+				switch {
+				case err == ErrCSRFMismatch:
+					http.Error(w, err.Error(), http.StatusForbidden)
+				case errors.Is(err, errTokenLookup):
+					logging.FromContext(r.Context()).Error("failed to verify api token", "error", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				default:
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				}
 				return
 			}
+			logging.SetUserID(r.Context(), claims.ID)
+			// Personal access tokens are limited to their scopes, they never
+			// carry the permissions of the user's roles.
+			if claims.APITokenID == 0 {
+				claims.Permissions, err = permissions.GetUserPermissions(r.Context(), claims.ID)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("failed to load permissions", "error", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
 			ctx := context.WithValue(r.Context(), AuthKey{}, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func verifyClaims(r *http.Request, tokenMaker *token.JWTMaker) (*AccessClaims, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return nil, errors.New("authorization header is missing")
+func verifyClaims(r *http.Request, tokenMaker token.Maker, apiTokens APITokenStore) (*AccessClaims, error) {
+	accessToken, err := extractToken(r)
+	if err != nil {
+		return nil, err
 	}
-	fields := strings.Fields(authHeader)
-	if len(fields) != 2 || fields[0] != "Bearer" {
-		return nil, errors.New("invalid authorization header")
+
+	if token.IsAPIToken(accessToken) {
+		return verifyAPIToken(r.Context(), apiTokens, accessToken)
 	}
-	token := fields[1]
 
-	calims, err := tokenMaker.VerifyToken(token)
+	calims, err := tokenMaker.VerifyToken(accessToken)
 	if err != nil {
 		return nil, err
 	}
-	return &AccessClaims{AccesToken: token, UserClaims: calims}, nil
+	return &AccessClaims{AccesToken: accessToken, Scopes: token.AllScopes, UserClaims: calims}, nil
+}
+
+// extractToken prefers the Authorization header used by API clients and falls back
+// to the browser session cookie, which additionally has to pass the csrf check.
+func extractToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		cookie, err := r.Cookie(AccessTokenCookie)
+		if err != nil || cookie.Value == "" {
+			return "", errors.New("authorization header is missing")
+		}
+		if err = CheckCSRF(r); err != nil {
+			return "", err
+		}
+		return cookie.Value, nil
+	}
+	fields := strings.Fields(authHeader)
+	if len(fields) != 2 || fields[0] != "Bearer" {
+		return "", errors.New("invalid authorization header")
+	}
+	return fields[1], nil
 }
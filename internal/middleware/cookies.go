@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFCookie         = "csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+
+	refreshTokenCookiePath = "/token"
+	csrfTokenSize          = 32
+)
+
+var ErrCSRFMismatch = errors.New("missing or invalid csrf token")
+
+// CookieConfig controls the cookies used by browser sessions.
+type CookieConfig struct {
+	Secure   bool
+	Domain   string
+	SameSite http.SameSite
+}
+
+// SetSessionCookies stores both tokens in HttpOnly cookies and returns a fresh csrf token.
+// The csrf cookie is readable by scripts so the frontend can echo it in the X-CSRF-Token header.
+func (c CookieConfig) SetSessionCookies(w http.ResponseWriter, accessToken string, accessExpires time.Time, refreshToken string, refreshExpires time.Time) (string, error) {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	c.SetAccessCookie(w, accessToken, accessExpires)
+	http.SetCookie(w, c.cookie(RefreshTokenCookie, refreshToken, refreshTokenCookiePath, refreshExpires, true))
+	http.SetCookie(w, c.cookie(CSRFCookie, csrfToken, "/", refreshExpires, false))
+	return csrfToken, nil
+}
+
+func (c CookieConfig) SetAccessCookie(w http.ResponseWriter, accessToken string, expires time.Time) {
+	http.SetCookie(w, c.cookie(AccessTokenCookie, accessToken, "/", expires, true))
+}
+
+func (c CookieConfig) ClearSessionCookies(w http.ResponseWriter) {
+	expired := time.Unix(0, 0)
+	http.SetCookie(w, c.cookie(AccessTokenCookie, "", "/", expired, true))
+	http.SetCookie(w, c.cookie(RefreshTokenCookie, "", refreshTokenCookiePath, expired, true))
+	http.SetCookie(w, c.cookie(CSRFCookie, "", "/", expired, false))
+}
+
+func (c CookieConfig) cookie(name string, value string, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.Domain,
+		Expires:  expires,
+		Secure:   c.Secure,
+		HttpOnly: httpOnly,
+		SameSite: c.SameSite,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// CheckCSRF implements the double-submit check: the header must repeat the csrf cookie.
+// Safe methods don't change state and are let through.
+func CheckCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return ErrCSRFMismatch
+	}
+	header := r.Header.Get(CSRFHeader)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrCSRFMismatch
+	}
+	return nil
+}
+
+func newCSRFToken() (string, error) {
+	buffer := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"image-sharing/internal/logging"
+	"image-sharing/internal/metrics"
+)
+
+// Logger puts a request logger into the context and writes one access log
+// line per request. It must run after RequestID.
+func Logger(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			logger := base.With("request_id", GetRequestID(r.Context()))
+			ctx := logging.WithLogger(r.Context(), logger)
+			ww := metrics.NewResponseWriter(w)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			logging.FromContext(ctx).Info("request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"duration_ms", float64(time.Since(start).Microseconds())/1000,
+				"remote_ip", ClientIP(r),
+			)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import "net/http"
+
+// RequirePermission must be used after the auth middleware.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(AuthKey{}).(*AccessClaims)
+			if !ok {
+				http.Error(w, "invalid claims", http.StatusUnauthorized)
+				return
+			}
+			if !claims.HasPermission(permission) {
+				http.Error(w, "missing permission: "+permission, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
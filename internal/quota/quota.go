@@ -0,0 +1,112 @@
+package quota
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Names of the limits, reported in ExceededError.
+const (
+	LimitBytes         = "bytes"
+	LimitPosts         = "posts"
+	LimitFileSize      = "file_size"
+	LimitUploadsPerDay = "uploads_per_day"
+)
+
+// UploadsPeriod is the window MaxUploadsPerDay is counted over.
+const UploadsPeriod = 24 * time.Hour
+
+const unlimited int64 = 0
+
+// Limits a user is held to. Zero means no limit.
+type Limits struct {
+	MaxBytes         int64
+	MaxPosts         int64
+	MaxFileSize      int64
+	MaxUploadsPerDay int64
+}
+
+// Usage is what a user currently takes. UploadsToday counts the posts created
+// during the last UploadsPeriod. PendingBytes is reserved by resumable uploads
+// that are still being sent and counts against MaxBytes like stored bytes.
+type Usage struct {
+	Bytes        int64
+	Posts        int64
+	UploadsToday int64
+	PendingBytes int64
+}
+
+// used is the space taken or reserved.
+func (u Usage) used() int64 {
+	return u.Bytes + u.PendingBytes
+}
+
+// ExceededError is returned when an upload would go over a limit.
+type ExceededError struct {
+	Limit string
+}
+
+func (e *ExceededError) Error() string {
+	return "quota exceeded: " + e.Limit
+}
+
+// IsExceeded reports whether err was caused by a quota.
+func IsExceeded(err error) bool {
+	var exceeded *ExceededError
+	return errors.As(err, &exceeded)
+}
+
+// Check returns the first limit a new upload of size bytes would go over.
+// A size of zero checks only that another upload is possible at all.
+func (l Limits) Check(usage Usage, size int64) error {
+	switch {
+	case l.MaxPosts != unlimited && usage.Posts >= l.MaxPosts:
+		return &ExceededError{Limit: LimitPosts}
+	case l.MaxUploadsPerDay != unlimited && usage.UploadsToday >= l.MaxUploadsPerDay:
+		return &ExceededError{Limit: LimitUploadsPerDay}
+	case l.MaxFileSize != unlimited && size > l.MaxFileSize:
+		return &ExceededError{Limit: LimitFileSize}
+	case l.MaxBytes != unlimited && (usage.used() >= l.MaxBytes || usage.used()+size > l.MaxBytes):
+		return &ExceededError{Limit: LimitBytes}
+	}
+	return nil
+}
+
+// Reader wraps an upload so reading fails with an ExceededError as soon as it
+// goes over the file size limit or the bytes left in the quota.
+func (l Limits) Reader(usage Usage, r io.Reader) io.Reader {
+	reader := &limitedReader{r: r, remaining: -1}
+	if l.MaxFileSize != unlimited {
+		reader.remaining, reader.limit = l.MaxFileSize, LimitFileSize
+	}
+	if l.MaxBytes != unlimited {
+		left := max(l.MaxBytes-usage.used(), 0)
+		if reader.remaining < 0 || left < reader.remaining {
+			reader.remaining, reader.limit = left, LimitBytes
+		}
+	}
+	if reader.remaining < 0 {
+		return r
+	}
+	return reader
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     string
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Allow one byte past the limit to tell an exact fit from an overflow.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, &ExceededError{Limit: l.limit}
+	}
+	return n, err
+}
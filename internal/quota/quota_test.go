@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{MaxBytes: 1000, MaxPosts: 10, MaxFileSize: 300, MaxUploadsPerDay: 5}
+	tests := []struct {
+		name   string
+		limits Limits
+		usage  Usage
+		size   int64
+		want   string
+	}{
+		{name: "unlimited", limits: Limits{}, usage: Usage{Bytes: 1 << 40, Posts: 1 << 20, UploadsToday: 1 << 20}, size: 1 << 40},
+		{name: "fits", limits: limits, usage: Usage{Bytes: 500, Posts: 3, UploadsToday: 1}, size: 300},
+		{name: "exact fit", limits: limits, usage: Usage{Bytes: 700}, size: 300},
+		{name: "too many posts", limits: limits, usage: Usage{Posts: 10}, want: LimitPosts},
+		{name: "too many uploads today", limits: limits, usage: Usage{UploadsToday: 5}, want: LimitUploadsPerDay},
+		{name: "file too large", limits: limits, size: 301, want: LimitFileSize},
+		{name: "over bytes", limits: limits, usage: Usage{Bytes: 701}, size: 300, want: LimitBytes},
+		{name: "bytes used up", limits: limits, usage: Usage{Bytes: 1000}, want: LimitBytes},
+		{name: "pending uploads reserve bytes", limits: limits, usage: Usage{Bytes: 400, PendingBytes: 400}, size: 201, want: LimitBytes},
+		{name: "pending uploads fill the quota", limits: limits, usage: Usage{PendingBytes: 1000}, want: LimitBytes},
+		{name: "posts are checked first", limits: limits, usage: Usage{Bytes: 1000, Posts: 10, UploadsToday: 5}, size: 301, want: LimitPosts},
+	}
+	for _, test := range tests {
+		err := test.limits.Check(test.usage, test.size)
+		got := ""
+		if err != nil {
+			exceeded, ok := err.(*ExceededError)
+			if !ok {
+				t.Fatalf("%s: unexpected error %v", test.name, err)
+			}
+			got = exceeded.Limit
+		}
+		if got != test.want {
+			t.Errorf("%s: got limit %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLimitsReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		usage  Usage
+		size   int
+		want   string
+	}{
+		{name: "unlimited", size: 1000},
+		{name: "exact file size", limits: Limits{MaxFileSize: 100}, size: 100},
+		{name: "over file size", limits: Limits{MaxFileSize: 100}, size: 101, want: LimitFileSize},
+		{name: "bytes left", limits: Limits{MaxBytes: 1000}, usage: Usage{Bytes: 900}, size: 100},
+		{name: "over bytes left", limits: Limits{MaxBytes: 1000}, usage: Usage{Bytes: 900, PendingBytes: 50}, size: 51, want: LimitBytes},
+		{name: "smaller limit wins", limits: Limits{MaxBytes: 1000, MaxFileSize: 500}, usage: Usage{Bytes: 800}, size: 201, want: LimitBytes},
+		{name: "quota exhausted", limits: Limits{MaxBytes: 1000}, usage: Usage{Bytes: 2000}, size: 1, want: LimitBytes},
+	}
+	for _, test := range tests {
+		source := strings.NewReader(strings.Repeat("x", test.size))
+		read, err := io.Copy(io.Discard, test.limits.Reader(test.usage, source))
+		got := ""
+		if err != nil {
+			if !IsExceeded(err) {
+				t.Fatalf("%s: unexpected error %v", test.name, err)
+			}
+			got = err.(*ExceededError).Limit
+		}
+		if got != test.want {
+			t.Errorf("%s: got limit %q, want %q", test.name, got, test.want)
+		}
+		if got == "" && read != int64(test.size) {
+			t.Errorf("%s: read %d of %d bytes", test.name, read, test.size)
+		}
+	}
+	// Short reads must not be mistaken for an overflow.
+	one := Limits{MaxFileSize: 3}.Reader(Usage{}, bytes.NewReader([]byte("abc")))
+	if data, err := io.ReadAll(one); err != nil || string(data) != "abc" {
+		t.Errorf("exact fit read %q, %v", data, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket holding Limit tokens that refills completely over Period.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// interval is the time it takes to refill a single token.
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+var (
+	// PolicyDefault applies to every request of a client IP.
+	PolicyDefault = Policy{Name: "default", Limit: 600, Period: time.Minute}
+	// PolicyAuth covers login and token renewal, on top of the bruteforce tracker.
+	PolicyAuth = Policy{Name: "auth", Limit: 20, Period: time.Minute}
+	// PolicyRegister limits account creation.
+	PolicyRegister = Policy{Name: "register", Limit: 5, Period: time.Hour}
+	// PolicyAuthenticated applies to every authenticated request of a user or API token.
+	PolicyAuthenticated = Policy{Name: "authenticated", Limit: 300, Period: time.Minute}
+	// PolicyUpload limits uploads, which are by far the most expensive requests.
+	PolicyUpload = Policy{Name: "upload", Limit: 30, Period: time.Hour}
+)
+
+// Store keeps the theoretical arrival time (TAT) of every key, which fully
+// describes a token bucket: the bucket is full once the TAT is in the past.
+// Take must advance the TAT atomically so replicas sharing a store agree.
+type Store interface {
+	// Take advances the TAT of key by interval if the result is at most burst
+	// ahead of now. It returns the current TAT and whether a token was taken.
+	Take(ctx context.Context, key string, now time.Time, interval, burst time.Duration) (time.Time, bool, error)
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, set when the request was denied.
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow takes a token from the bucket of subject under policy.
+func (l *Limiter) Allow(ctx context.Context, policy Policy, subject string) (Result, error) {
+	if policy.Limit <= 0 || policy.Period <= 0 {
+		return Result{}, fmt.Errorf("invalid rate limit policy %q", policy.Name)
+	}
+	now := l.now()
+	interval := policy.interval()
+	tat, allowed, err := l.store.Take(ctx, Key(policy, subject), now, interval, policy.Period)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Allowed: allowed, Limit: policy.Limit, Reset: max(tat.Sub(now), 0)}
+	if allowed {
+		result.Remaining = int((policy.Period - tat.Sub(now)) / interval)
+	} else {
+		result.RetryAfter = max(tat.Add(interval).Sub(now.Add(policy.Period)), 0)
+	}
+	return result, nil
+}
+
+// maxSubjectLength keeps keys within the rate_limits.key column.
+const maxSubjectLength = 255
+
+func Key(policy Policy, subject string) string {
+	if len(subject) > maxSubjectLength {
+		subject = strings.ToValidUTF8(subject[:maxSubjectLength], "")
+	}
+	return policy.Name + ":" + subject
+}
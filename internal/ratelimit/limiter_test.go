@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	limiter := NewLimiter(NewMemoryStore())
+	limiter.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    Result
+	}{
+		{name: "first", want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+		{name: "second", want: Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}},
+		{name: "last token", want: Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		{name: "empty", want: Result{Allowed: false, Limit: 3, Reset: 3 * time.Second, RetryAfter: time.Second}},
+		{name: "half refilled", advance: 500 * time.Millisecond, want: Result{Allowed: false, Limit: 3, Reset: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{name: "refilled one", advance: 500 * time.Millisecond, want: Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		{name: "full again", advance: time.Minute, want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		got, err := limiter.Allow(context.Background(), policy, "subject")
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestLimiterSeparatesSubjectsAndPolicies(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore())
+	single := Policy{Name: "single", Limit: 1, Period: time.Hour}
+	other := Policy{Name: "other", Limit: 1, Period: time.Hour}
+
+	tests := []struct {
+		policy  Policy
+		subject string
+		allowed bool
+	}{
+		{single, "a", true},
+		{single, "a", false},
+		{single, "b", true},
+		{other, "a", true},
+	}
+	for _, test := range tests {
+		got, err := limiter.Allow(context.Background(), test.policy, test.subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Allowed != test.allowed {
+			t.Errorf("%s/%s: allowed = %v, want %v", test.policy.Name, test.subject, got.Allowed, test.allowed)
+		}
+	}
+}
+
+func TestLimiterRejectsInvalidPolicy(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore())
+	for _, policy := range []Policy{
+		{Name: "no limit", Period: time.Minute},
+		{Name: "no period", Limit: 1},
+		{Name: "negative", Limit: -1, Period: time.Minute},
+	} {
+		if _, err := limiter.Allow(context.Background(), policy, "subject"); err == nil {
+			t.Errorf("%s: expected an error", policy.Name)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	policy := Policy{Name: "default"}
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{name: "short", subject: "203.0.113.7", want: "default:203.0.113.7"},
+		{name: "truncated", subject: strings.Repeat("a", 300), want: "default:" + strings.Repeat("a", maxSubjectLength)},
+		{name: "split rune dropped", subject: strings.Repeat("a", maxSubjectLength-1) + "é", want: "default:" + strings.Repeat("a", maxSubjectLength-1)},
+	}
+	for _, test := range tests {
+		if got := Key(policy, test.subject); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
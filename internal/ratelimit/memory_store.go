@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memoryStoreSweepSize = 10000
+
+// MemoryStore keeps buckets of a single replica.
+type MemoryStore struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, now time.Time, interval, burst time.Duration) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.tats) >= memoryStoreSweepSize {
+		s.sweep(now)
+	}
+
+	tat, ok := s.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	if next.Sub(now) > burst {
+		return tat, false, nil
+	}
+	s.tats[key] = next
+	return next, true, nil
+}
+
+// sweep forgets full buckets, they behave exactly like missing ones.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, tat := range s.tats {
+		if tat.Before(now) {
+			delete(s.tats, key)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+)
+
+// PostgresStore shares buckets between replicas.
+type PostgresStore struct {
+	queries *db.Queries
+}
+
+func NewPostgresStore(queries *db.Queries) *PostgresStore {
+	return &PostgresStore{queries: queries}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, now time.Time, interval, burst time.Duration) (time.Time, bool, error) {
+	tat, err := s.queries.TakeRateLimit(ctx, db.TakeRateLimitParams{
+		Key:             key,
+		Now:             now,
+		IntervalSeconds: interval.Seconds(),
+		BurstSeconds:    burst.Seconds(),
+	})
+	if err == nil {
+		return tat, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return time.Time{}, false, err
+	}
+
+	// The conditional upsert returns no row when the bucket is empty.
+	tat, err = s.queries.GetRateLimit(ctx, key)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return tat, false, nil
+}
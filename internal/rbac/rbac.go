@@ -0,0 +1,23 @@
+package rbac
+
+// Built-in roles, seeded by the schema.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permissions checked by handlers. The permissions table is the source of truth
+// for what roles can be granted, these are the names the code relies on.
+const (
+	PostDeleteAny = "post.delete.any"
+	PostPurge     = "post.purge"
+	UserUpdateAny = "user.update.any"
+	UserDeleteAny = "user.delete.any"
+	UserBan       = "user.ban"
+	ReportReview  = "report.review"
+	RoleManage    = "role.manage"
+	AuthUnlock    = "auth.unlock"
+	AuditRead     = "audit.read"
+	QuotaManage   = "quota.manage"
+)
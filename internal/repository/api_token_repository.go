@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"image-sharing/internal/db/gen"
+)
+
+type APITokenRepository interface {
+	CreateAPIToken(ctx context.Context, apiToken db.CreateAPITokenParams) (db.ApiToken, error)
+	ListAPITokens(ctx context.Context, userID int32) ([]db.ApiToken, error)
+	GetAPITokenByPrefix(ctx context.Context, prefix string) (db.GetAPITokenByPrefixRow, error)
+	TouchAPIToken(ctx context.Context, id int32) error
+	RevokeAPIToken(ctx context.Context, id int32, userID int32) error
+}
+
+type apiTokenRepository struct {
+	db      *sql.DB
+	queries *db.Queries
+}
+
+func NewAPITokenRepository(db *sql.DB, queries *db.Queries) APITokenRepository {
+	return &apiTokenRepository{db: db, queries: queries}
+}
+
+func (r *apiTokenRepository) CreateAPIToken(ctx context.Context, apiToken db.CreateAPITokenParams) (db.ApiToken, error) {
+	return r.queries.CreateAPIToken(ctx, apiToken)
+}
+
+func (r *apiTokenRepository) ListAPITokens(ctx context.Context, userID int32) ([]db.ApiToken, error) {
+	return r.queries.ListAPITokens(ctx, userID)
+}
+
+func (r *apiTokenRepository) GetAPITokenByPrefix(ctx context.Context, prefix string) (db.GetAPITokenByPrefixRow, error) {
+	apiToken, err := r.queries.GetAPITokenByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.GetAPITokenByPrefixRow{}, ErrNotFound
+		}
+		return db.GetAPITokenByPrefixRow{}, err
+	}
+	return apiToken, nil
+}
+
+func (r *apiTokenRepository) TouchAPIToken(ctx context.Context, id int32) error {
+	return r.queries.TouchAPIToken(ctx, id)
+}
+
+func (r *apiTokenRepository) RevokeAPIToken(ctx context.Context, id int32, userID int32) error {
+	rows, err := r.queries.RevokeAPIToken(ctx, db.RevokeAPITokenParams{ID: id, UserID: userID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
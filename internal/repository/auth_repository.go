@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"image-sharing/internal/db/gen"
 )
@@ -14,6 +15,7 @@ type AuthRepository interface {
 	RevokeSession(ctx context.Context, accessToken string) error
 	RevokeAllSessions(ctx context.Context, login string) error
 	RenewSession(ctx context.Context, id string, accessToken string) error
+	UpdatePasswordHash(ctx context.Context, userID int32, passwordHash string) error
 }
 
 type authRepository struct {
@@ -60,9 +62,13 @@ func (r *authRepository) RenewSession(ctx context.Context, id string, accessToke
 }
 
 func (r *authRepository) RevokeSession(ctx context.Context, accessToken string) error {
-	return r.queries.RevokeSession(ctx, accessToken)
+	return r.queries.RevokeSession(ctx, db.RevokeSessionParams{RevokedAt: time.Now(), AccessToken: accessToken})
 }
 
 func (r *authRepository) RevokeAllSessions(ctx context.Context, login string) error {
-	return r.queries.RevokeSessionsByLogin(ctx, login)
+	return r.queries.RevokeSessionsByLogin(ctx, db.RevokeSessionsByLoginParams{RevokedAt: time.Now(), UserLogin: login})
+}
+
+func (r *authRepository) UpdatePasswordHash(ctx context.Context, userID int32, passwordHash string) error {
+	return r.queries.UpdatePasswordHash(ctx, db.UpdatePasswordHashParams{UserID: userID, PasswordHash: passwordHash})
 }
@@ -3,31 +3,46 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"io"
-	"mime/multipart"
-	"os"
-	"path/filepath"
+	"time"
 
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"image-sharing/internal/db/gen"
+	"image-sharing/internal/quota"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
 )
 
 type PostRepository interface {
 	GetPostByID(ctx context.Context, id int) (db.GetPostRow, error)
 	GetAllPosts(ctx context.Context, page int, limit int) ([]db.ListPostsRow, int64, error)
-	CreatePost(ctx context.Context, post db.CreatePostParams, file multipart.File, format string) (db.Post, error)
+	CreatePost(ctx context.Context, post db.CreatePostParams, media io.Reader, format string, uploadID string) (db.Post, error)
 	GetPostUserID(ctx context.Context, id int) (int32, error)
-	DeletePost(ctx context.Context, id int) error
+	GetPostMetadata(ctx context.Context, id int) ([]byte, error)
+	GetTrashedPostUserID(ctx context.Context, id int) (int32, error)
+	ListTrashedPosts(ctx context.Context, userID int32) ([]db.Post, error)
+	TrashPost(ctx context.Context, id int) error
+	RestorePost(ctx context.Context, id int) error
+	DeletePost(ctx context.Context, id int) (db.Post, error)
+}
+
+// MetadataSource is media that had its metadata stripped while being read.
+// Whatever it returns afterwards is stored privately with the post.
+type MetadataSource interface {
+	Metadata() []byte
 }
 
 type postRepository struct {
-	db        *sql.DB
-	queries   *db.Queries
-	uploadDir string
+	db            *sql.DB
+	queries       *db.Queries
+	storage       *storage.Filesystem
+	reuseExisting bool
+	quotas        quota.Limits
 }
 
-func NewPostRepository(db *sql.DB, queries *db.Queries, uploadDir string) PostRepository {
-	return &postRepository{db: db, queries: queries, uploadDir: uploadDir}
+func NewPostRepository(db *sql.DB, queries *db.Queries, store *storage.Filesystem, reuseExisting bool, quotas quota.Limits) PostRepository {
+	return &postRepository{db: db, queries: queries, storage: store, reuseExisting: reuseExisting, quotas: quotas}
 }
 
 func (r *postRepository) GetPostByID(ctx context.Context, id int) (db.GetPostRow, error) {
@@ -57,32 +72,159 @@ func (r *postRepository) GetAllPosts(ctx context.Context, page int, limit int) (
 	return posts, count, nil
 }
 
-func (r *postRepository) CreatePost(ctx context.Context, post db.CreatePostParams, file multipart.File, format string) (db.Post, error) {
+// CreatePost streams media into the store and records the post. Media is
+// stored once per SHA-256, duplicates only add a reference to the blob. When
+// reuseExisting is set and the user already posted the same file, that post
+// is returned instead of creating another one.
+//
+// Media of a resumable upload passes its id, the upload is linked to the post
+// in the same transaction. ErrUploadCompleted means another request already
+// made a post of it, nothing is created then.
+//
+// The quota of the user is checked before anything is written and the write
+// stops once the upload outgrows it. The check is repeated with the final size
+// while the user row is locked, so concurrent uploads cannot overshoot.
+func (r *postRepository) CreatePost(ctx context.Context, post db.CreatePostParams, media io.Reader, format string, uploadID string) (db.Post, error) {
+	limits, err := loadLimits(ctx, r.queries, r.quotas, post.UserID)
+	if err != nil {
+		return db.Post{}, err
+	}
+	usage, err := loadUsage(ctx, r.queries, post.UserID)
+	if err != nil {
+		return db.Post{}, err
+	}
+	if err = limits.Check(usage, 0); err != nil {
+		return db.Post{}, err
+	}
 
-	newFilename := uuid.New().String() + format
-	uploadPath := filepath.Join(r.uploadDir, newFilename)
+	blob, err := writeBlob(ctx, r.storage, limits.Reader(usage, media))
+	if err != nil {
+		return db.Post{}, err
+	}
+	defer blob.Abort()
+	post.SizeBytes = blob.Size()
+	post.Sha256 = blob.SHA256()
 
-	dst, err := os.Create(uploadPath)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return db.Post{}, err
 	}
-	defer dst.Close()
+	defer tx.Rollback()
+	queries := db.New(tracing.WrapDB(tx))
+
+	if uploadID != "" {
+		postID, err := queries.ClaimUpload(ctx, uploadID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return db.Post{}, ErrNotFound
+			}
+			return db.Post{}, err
+		}
+		if postID.Valid {
+			return db.Post{}, ErrUploadCompleted
+		}
+	}
+
+	if r.reuseExisting {
+		existing, err := queries.GetUserPostBySha256(ctx, db.GetUserPostBySha256Params{UserID: post.UserID, Sha256: post.Sha256})
+		if err == nil {
+			if err = completeUpload(ctx, queries, uploadID, existing.ID); err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				return db.Post{}, err
+			}
+			return existing, nil
+		}
+		if err != sql.ErrNoRows {
+			return db.Post{}, err
+		}
+	}
+
+	if _, err = queries.LockUserQuota(ctx, post.UserID); err != nil {
+		return db.Post{}, err
+	}
+	if limits, err = loadLimits(ctx, queries, r.quotas, post.UserID); err != nil {
+		return db.Post{}, err
+	}
+	if usage, err = loadUsage(ctx, queries, post.UserID); err != nil {
+		return db.Post{}, err
+	}
+	if err = limits.Check(usage, post.SizeBytes); err != nil {
+		return db.Post{}, err
+	}
 
-	if _, err := io.Copy(dst, file); err != nil {
-		os.Remove(uploadPath)
+	stored, err := queries.AcquireBlob(ctx, db.AcquireBlobParams{
+		Sha256:      post.Sha256,
+		Path:        r.storage.Path(storage.ContentName(post.Sha256, format)),
+		SizeBytes:   post.SizeBytes,
+		ContentType: post.ContentType,
+	})
+	if err != nil {
 		return db.Post{}, err
 	}
 
-	post.ImagePath = uploadPath
-	createdPost, err := r.queries.CreatePost(ctx, post)
+	post.ImagePath = stored.Path
+	createdPost, err := queries.CreatePost(ctx, post)
 	if err != nil {
-		os.Remove(uploadPath)
+		return db.Post{}, err
+	}
+	if source, ok := media.(MetadataSource); ok {
+		if exif := source.Metadata(); exif != nil {
+			err = queries.CreatePostMetadata(ctx, db.CreatePostMetadataParams{PostID: createdPost.ID, Exif: exif})
+			if err != nil {
+				return db.Post{}, err
+			}
+		}
+	}
+	if err = completeUpload(ctx, queries, uploadID, createdPost.ID); err != nil {
+		return db.Post{}, err
+	}
+	if err = tx.Commit(); err != nil {
 		return db.Post{}, err
 	}
 
+	// The file is moved into place once the post is recorded, a failed
+	// transaction leaves nothing behind. It is written even when it already
+	// exists, the content is identical and a missing file gets restored. Should
+	// that fail the post is removed again, which queues the blob for deletion
+	// and unlinks the upload so it can be published again.
+	if err = blob.Commit(stored.Path); err != nil {
+		if _, deleteErr := r.queries.DeletePost(context.WithoutCancel(ctx), createdPost.ID); deleteErr != nil {
+			return db.Post{}, errors.Join(err, deleteErr)
+		}
+		return db.Post{}, err
+	}
 	return createdPost, nil
 }
 
+// completeUpload links a resumable upload, if any, to the post made of it.
+func completeUpload(ctx context.Context, queries *db.Queries, uploadID string, postID int32) error {
+	if uploadID == "" {
+		return nil
+	}
+	return queries.CompleteUpload(ctx, db.CompleteUploadParams{ID: uploadID, PostID: sql.NullInt32{Int32: postID, Valid: true}})
+}
+
+// writeBlob streams media into a new uncommitted blob.
+func writeBlob(ctx context.Context, store *storage.Filesystem, media io.Reader) (blob *storage.Blob, err error) {
+	_, span := tracing.Start(ctx, "storage.write")
+	defer func() { tracing.End(span, err) }()
+
+	blob, err = store.Create()
+	if err != nil {
+		return nil, err
+	}
+	written, err := io.Copy(blob, media)
+	span.SetAttributes(attribute.Int64("storage.bytes", written))
+	if err != nil {
+		blob.Abort()
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("storage.sha256", blob.SHA256()))
+	return blob, nil
+}
+
 func (r *postRepository) GetPostUserID(ctx context.Context, id int) (int32, error) {
 	post, err := r.queries.GetPostUserID(ctx, int32(id))
 	if err != nil {
@@ -93,6 +235,71 @@ func (r *postRepository) GetPostUserID(ctx context.Context, id int) (int32, erro
 	}
 	return post, nil
 }
-func (r *postRepository) DeletePost(ctx context.Context, id int) error {
-	return r.queries.DeletPost(ctx, int32(id))
+
+func (r *postRepository) GetPostMetadata(ctx context.Context, id int) ([]byte, error) {
+	exif, err := r.queries.GetPostMetadata(ctx, int32(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return exif, nil
+}
+
+func (r *postRepository) GetTrashedPostUserID(ctx context.Context, id int) (int32, error) {
+	userID, err := r.queries.GetTrashedPostUserID(ctx, int32(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *postRepository) ListTrashedPosts(ctx context.Context, userID int32) ([]db.Post, error) {
+	return r.queries.ListTrashedPosts(ctx, userID)
+}
+
+// TrashPost hides the post from all reads. It stays restorable until the
+// trash retention job purges it.
+func (r *postRepository) TrashPost(ctx context.Context, id int) error {
+	trashed, err := r.queries.TrashPost(ctx, db.TrashPostParams{
+		ID:        int32(id),
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if trashed == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postRepository) RestorePost(ctx context.Context, id int) error {
+	restored, err := r.queries.RestorePost(ctx, int32(id))
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeletePost removes the post permanently, whether it is in the trash or not.
+// Files are not touched here: the database queues the blob for removal in the
+// same transaction once no post references it, and the storage cleaner
+// deletes it afterwards.
+func (r *postRepository) DeletePost(ctx context.Context, id int) (db.Post, error) {
+	post, err := r.queries.DeletePost(ctx, int32(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Post{}, ErrNotFound
+		}
+		return db.Post{}, err
+	}
+	return post, nil
 }
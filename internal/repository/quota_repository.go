@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"image-sharing/internal/db/gen"
+	"image-sharing/internal/quota"
+)
+
+type QuotaRepository interface {
+	GetLimits(ctx context.Context, userID int32) (quota.Limits, error)
+	GetUsage(ctx context.Context, userID int32) (quota.Usage, error)
+	GetOverride(ctx context.Context, userID int32) (db.UserQuota, error)
+	SetOverride(ctx context.Context, override db.SetUserQuotaParams) (db.UserQuota, error)
+	DeleteOverride(ctx context.Context, userID int32) error
+	Defaults() quota.Limits
+}
+
+type quotaRepository struct {
+	db       *sql.DB
+	queries  *db.Queries
+	defaults quota.Limits
+}
+
+func NewQuotaRepository(db *sql.DB, queries *db.Queries, defaults quota.Limits) QuotaRepository {
+	return &quotaRepository{db: db, queries: queries, defaults: defaults}
+}
+
+func (r *quotaRepository) Defaults() quota.Limits {
+	return r.defaults
+}
+
+func (r *quotaRepository) GetLimits(ctx context.Context, userID int32) (quota.Limits, error) {
+	return loadLimits(ctx, r.queries, r.defaults, userID)
+}
+
+func (r *quotaRepository) GetUsage(ctx context.Context, userID int32) (quota.Usage, error) {
+	return loadUsage(ctx, r.queries, userID)
+}
+
+func (r *quotaRepository) GetOverride(ctx context.Context, userID int32) (db.UserQuota, error) {
+	override, err := r.queries.GetUserQuota(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.UserQuota{}, ErrNotFound
+		}
+		return db.UserQuota{}, err
+	}
+	return override, nil
+}
+
+func (r *quotaRepository) SetOverride(ctx context.Context, override db.SetUserQuotaParams) (db.UserQuota, error) {
+	saved, err := r.queries.SetUserQuota(ctx, override)
+	if err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return db.UserQuota{}, ErrNotFound
+		}
+		return db.UserQuota{}, err
+	}
+	return saved, nil
+}
+
+func (r *quotaRepository) DeleteOverride(ctx context.Context, userID int32) error {
+	deleted, err := r.queries.DeleteUserQuota(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// loadLimits returns the defaults with the overrides of the user applied.
+func loadLimits(ctx context.Context, queries *db.Queries, defaults quota.Limits, userID int32) (quota.Limits, error) {
+	override, err := queries.GetUserQuota(ctx, userID)
+	if err == sql.ErrNoRows {
+		return defaults, nil
+	}
+	if err != nil {
+		return quota.Limits{}, err
+	}
+	limits := defaults
+	if override.MaxBytes.Valid {
+		limits.MaxBytes = override.MaxBytes.Int64
+	}
+	if override.MaxPosts.Valid {
+		limits.MaxPosts = override.MaxPosts.Int64
+	}
+	if override.MaxFileSize.Valid {
+		limits.MaxFileSize = override.MaxFileSize.Int64
+	}
+	if override.MaxUploadsPerDay.Valid {
+		limits.MaxUploadsPerDay = override.MaxUploadsPerDay.Int64
+	}
+	return limits, nil
+}
+
+func loadUsage(ctx context.Context, queries *db.Queries, userID int32) (quota.Usage, error) {
+	usage, err := queries.GetUserUsage(ctx, db.GetUserUsageParams{
+		Since:  time.Now().Add(-quota.UploadsPeriod),
+		UserID: userID,
+	})
+	if err != nil {
+		return quota.Usage{}, err
+	}
+	return quota.Usage{Bytes: usage.Bytes, Posts: usage.Posts, UploadsToday: usage.UploadsSince, PendingBytes: usage.PendingBytes}, nil
+}
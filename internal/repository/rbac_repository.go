@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"image-sharing/internal/db/gen"
+	"image-sharing/internal/tracing"
+)
+
+var ErrBuiltinRole = errors.New("built-in roles can't be deleted")
+var ErrRoleExists = errors.New("role already exists")
+
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+type RoleWithPermissions struct {
+	db.Role
+	Permissions []string
+}
+
+type RBACRepository interface {
+	ListRoles(ctx context.Context) ([]RoleWithPermissions, error)
+	CreateRole(ctx context.Context, role db.CreateRoleParams, permissions []string) (RoleWithPermissions, error)
+	DeleteRole(ctx context.Context, name string) error
+	ListPermissions(ctx context.Context) ([]db.Permission, error)
+	ListUserRoles(ctx context.Context, userID int32) ([]db.Role, error)
+	AssignUserRole(ctx context.Context, userID int32, roleName string) error
+	RemoveUserRole(ctx context.Context, userID int32, roleName string) error
+	GetUserPermissions(ctx context.Context, userID int32) ([]string, error)
+}
+
+type rbacRepository struct {
+	db      *sql.DB
+	queries *db.Queries
+}
+
+func NewRBACRepository(db *sql.DB, queries *db.Queries) RBACRepository {
+	return &rbacRepository{db: db, queries: queries}
+}
+
+func (r *rbacRepository) ListRoles(ctx context.Context) ([]RoleWithPermissions, error) {
+	roles, err := r.queries.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rolePermissions, err := r.queries.ListRolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[int32][]string)
+	for _, rp := range rolePermissions {
+		permissions[rp.RoleID] = append(permissions[rp.RoleID], rp.Permission)
+	}
+
+	result := make([]RoleWithPermissions, len(roles))
+	for i, role := range roles {
+		result[i] = RoleWithPermissions{Role: role, Permissions: permissions[role.ID]}
+	}
+	return result, nil
+}
+
+func (r *rbacRepository) CreateRole(ctx context.Context, role db.CreateRoleParams, permissions []string) (RoleWithPermissions, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RoleWithPermissions{}, err
+	}
+	defer tx.Rollback()
+	qtx := db.New(tracing.WrapDB(tx))
+
+	createdRole, err := qtx.CreateRole(ctx, role)
+	if err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			return RoleWithPermissions{}, ErrRoleExists
+		}
+		return RoleWithPermissions{}, err
+	}
+	for _, permission := range permissions {
+		err = qtx.AddRolePermission(ctx, db.AddRolePermissionParams{RoleID: createdRole.ID, Permission: permission})
+		if err != nil {
+			return RoleWithPermissions{}, err
+		}
+	}
+
+	return RoleWithPermissions{Role: createdRole, Permissions: permissions}, tx.Commit()
+}
+
+func (r *rbacRepository) DeleteRole(ctx context.Context, name string) error {
+	role, err := r.getRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	rows, err := r.queries.DeleteRole(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrBuiltinRole
+	}
+	return nil
+}
+
+func (r *rbacRepository) ListPermissions(ctx context.Context) ([]db.Permission, error) {
+	return r.queries.ListPermissions(ctx)
+}
+
+func (r *rbacRepository) ListUserRoles(ctx context.Context, userID int32) ([]db.Role, error) {
+	return r.queries.ListUserRoles(ctx, userID)
+}
+
+func (r *rbacRepository) AssignUserRole(ctx context.Context, userID int32, roleName string) error {
+	role, err := r.getRole(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	err = r.queries.AssignUserRole(ctx, db.AssignUserRoleParams{UserID: userID, RoleID: role.ID})
+	if isPQError(err, pqForeignKeyViolation) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *rbacRepository) RemoveUserRole(ctx context.Context, userID int32, roleName string) error {
+	role, err := r.getRole(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	rows, err := r.queries.RemoveUserRole(ctx, db.RemoveUserRoleParams{UserID: userID, RoleID: role.ID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *rbacRepository) GetUserPermissions(ctx context.Context, userID int32) ([]string, error) {
+	return r.queries.GetUserPermissions(ctx, userID)
+}
+
+func (r *rbacRepository) getRole(ctx context.Context, name string) (db.Role, error) {
+	role, err := r.queries.GetRoleByName(ctx, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Role{}, ErrNotFound
+		}
+		return db.Role{}, err
+	}
+	return role, nil
+}
+
+func isPQError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
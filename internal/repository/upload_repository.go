@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"image-sharing/internal/db/gen"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
+)
+
+// ErrOffsetMismatch is returned when another request advanced the upload first.
+var ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+// ErrUploadLocked is returned while another request, possibly on another
+// replica, appends to the upload.
+var ErrUploadLocked = errors.New("upload is in use by another request")
+
+// ErrUploadCompleted is returned when another request already published the
+// upload.
+var ErrUploadCompleted = errors.New("upload already completed")
+
+// UploadRepository keeps resumable uploads: the row tracks the offset, the
+// data lives in a partial blob until the upload is complete.
+type UploadRepository interface {
+	CreateUpload(ctx context.Context, upload db.CreateUploadParams) (db.Upload, error)
+	GetUpload(ctx context.Context, id string) (db.Upload, error)
+	AppendUpload(ctx context.Context, upload db.Upload, data io.Reader, expiresAt time.Time) (int64, error)
+	OpenUpload(ctx context.Context, id string) (io.ReadCloser, error)
+	CompleteUpload(ctx context.Context, id string) error
+	DeleteUpload(ctx context.Context, id string) error
+	ListExpiredUploads(ctx context.Context, now time.Time, limit int32) ([]db.Upload, error)
+}
+
+type uploadRepository struct {
+	db      *sql.DB
+	queries *db.Queries
+	storage *storage.Filesystem
+}
+
+func NewUploadRepository(db *sql.DB, queries *db.Queries, store *storage.Filesystem) UploadRepository {
+	return &uploadRepository{db: db, queries: queries, storage: store}
+}
+
+func (r *uploadRepository) CreateUpload(ctx context.Context, upload db.CreateUploadParams) (db.Upload, error) {
+	if err := r.storage.CreatePartial(upload.ID); err != nil {
+		return db.Upload{}, err
+	}
+	created, err := r.queries.CreateUpload(ctx, upload)
+	if err != nil {
+		r.storage.RemovePartial(upload.ID)
+		return db.Upload{}, err
+	}
+	return created, nil
+}
+
+func (r *uploadRepository) GetUpload(ctx context.Context, id string) (db.Upload, error) {
+	upload, err := r.queries.GetUpload(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Upload{}, ErrNotFound
+		}
+		return db.Upload{}, err
+	}
+	return upload, nil
+}
+
+// AppendUpload writes data at the current offset of upload and records the
+// new offset, also when data failed part way. It returns the bytes stored.
+// The upload row stays locked while the data is written, so requests on other
+// replicas never touch the partial file at the same time.
+func (r *uploadRepository) AppendUpload(ctx context.Context, upload db.Upload, data io.Reader, expiresAt time.Time) (written int64, err error) {
+	_, span := tracing.Start(ctx, "storage.append",
+		attribute.String("upload.id", upload.ID),
+		attribute.Int64("upload.offset", upload.UploadOffset))
+	defer func() { tracing.End(span, err) }()
+
+	// Bytes received before the client went away are kept, so the transaction
+	// outlives the request.
+	txCtx := context.WithoutCancel(ctx)
+	tx, err := r.db.BeginTx(txCtx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	queries := db.New(tracing.WrapDB(tx))
+
+	offset, err := queries.LockUpload(txCtx, upload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUploadLocked
+		}
+		return 0, err
+	}
+	if offset != upload.UploadOffset {
+		return 0, ErrOffsetMismatch
+	}
+
+	written, err = r.storage.AppendPartial(upload.ID, upload.UploadOffset, data)
+	span.SetAttributes(attribute.Int64("storage.bytes", written))
+	if written == 0 {
+		return 0, err
+	}
+
+	_, advanceErr := queries.AdvanceUpload(txCtx, db.AdvanceUploadParams{
+		NewOffset: upload.UploadOffset + written,
+		ExpiresAt: expiresAt,
+		ID:        upload.ID,
+		OldOffset: upload.UploadOffset,
+	})
+	if advanceErr == nil {
+		advanceErr = tx.Commit()
+	}
+	if advanceErr != nil {
+		return 0, advanceErr
+	}
+	return written, err
+}
+
+func (r *uploadRepository) OpenUpload(ctx context.Context, id string) (io.ReadCloser, error) {
+	return r.storage.OpenPartial(id)
+}
+
+// CompleteUpload drops the partial data of an upload that became a post, the
+// post itself links the upload when it is created.
+func (r *uploadRepository) CompleteUpload(ctx context.Context, id string) error {
+	return r.storage.RemovePartial(id)
+}
+
+func (r *uploadRepository) DeleteUpload(ctx context.Context, id string) error {
+	rows, err := r.queries.DeleteUpload(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err = r.storage.RemovePartial(id); err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *uploadRepository) ListExpiredUploads(ctx context.Context, now time.Time, limit int32) ([]db.Upload, error) {
+	return r.queries.ListExpiredUploads(ctx, db.ListExpiredUploadsParams{ExpiresAt: now, Limit: limit})
+}
@@ -6,9 +6,12 @@ import (
 	"errors"
 
 	"image-sharing/internal/db/gen"
+	"image-sharing/internal/rbac"
+	"image-sharing/internal/tracing"
 )
 
 var ErrNotFound = errors.New("not found")
+var ErrLoginTaken = errors.New("login is taken")
 
 type UserRepository interface {
 	GetUserByID(ctx context.Context, id int) (db.User, error)
@@ -43,7 +46,7 @@ func (r *userRepository) CreateUser(ctx context.Context, user db.CreateUserAuthP
 		return db.User{}, err
 	}
 	if exists {
-		return db.User{}, errors.New("login is taken")
+		return db.User{}, ErrLoginTaken
 	}
 
 	tx, err := r.db.Begin()
@@ -51,7 +54,7 @@ func (r *userRepository) CreateUser(ctx context.Context, user db.CreateUserAuthP
 		return db.User{}, err
 	}
 	defer tx.Rollback()
-	qtx := r.queries.WithTx(tx)
+	qtx := db.New(tracing.WrapDB(tx))
 
 	createdUser, err := qtx.CreateUser(ctx, db.CreateUserParams{Name: user.Login})
 	if err != nil {
@@ -64,6 +67,15 @@ func (r *userRepository) CreateUser(ctx context.Context, user db.CreateUserAuthP
 		return db.User{}, err
 	}
 
+	role, err := qtx.GetRoleByName(ctx, rbac.RoleUser)
+	if err != nil {
+		return db.User{}, err
+	}
+	err = qtx.AssignUserRole(ctx, db.AssignUserRoleParams{UserID: createdUser.ID, RoleID: role.ID})
+	if err != nil {
+		return db.User{}, err
+	}
+
 	return createdUser, tx.Commit()
 }
 
@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"image-sharing/internal/audit"
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/middleware"
+	"image-sharing/internal/repository"
+	"image-sharing/pkg/token"
+)
+
+const maxAPITokenNameLength = 255
+
+type APITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+type APITokenResponse struct {
+	ID         int32      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPITokenResponse struct {
+	APITokenResponse
+	Token string `json:"token"`
+}
+
+type APITokenRoute struct {
+	repo  repository.APITokenRepository
+	audit *audit.Service
+}
+
+func NewAPITokenRoute(repo repository.APITokenRepository, auditService *audit.Service) *APITokenRoute {
+	return &APITokenRoute{repo: repo, audit: auditService}
+}
+
+func (a *APITokenRoute) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, err := checkSessionClaims(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Name) > maxAPITokenNameLength {
+		http.Error(w, "invalid token name", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "at least one scope required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !token.IsValidScope(scope) {
+			http.Error(w, "unknown scope: "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "invalid expires_in_days", http.StatusBadRequest)
+		return
+	}
+
+	apiToken, prefix, hash, err := token.NewAPIToken()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if req.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, req.ExpiresInDays), Valid: true}
+	}
+
+	created, err := a.repo.CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    claims.ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	a.audit.Record(r, audit.Event{
+		Action:     audit.ActionAPITokenCreate,
+		TargetType: audit.TargetAPIToken,
+		TargetID:   strconv.Itoa(int(created.ID)),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"scopes": created.Scopes},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPITokenResponse{APITokenResponse: apiTokenToResponse(created), Token: apiToken})
+}
+
+func (a *APITokenRoute) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	claims, err := checkSessionClaims(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	apiTokens, err := a.repo.ListAPITokens(r.Context(), claims.ID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	result := make([]APITokenResponse, len(apiTokens))
+	for i, apiToken := range apiTokens {
+		result[i] = apiTokenToResponse(apiToken)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (a *APITokenRoute) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := checkSessionClaims(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = a.repo.RevokeAPIToken(r.Context(), int32(id), claims.ID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "token not found", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+
+	a.audit.Record(r, audit.Event{Action: audit.ActionAPITokenRevoke, TargetType: audit.TargetAPIToken, TargetID: strconv.Itoa(id), Outcome: audit.OutcomeSuccess})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSessionClaims only accepts login sessions, so a leaked api token can't be used to mint new ones.
+func checkSessionClaims(r *http.Request) (*middleware.AccessClaims, error) {
+	claims, err := CheckClaims(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	if claims.APITokenID != 0 {
+		return nil, errors.New("api tokens can't be managed with an api token")
+	}
+	return claims, nil
+}
+
+func apiTokenToResponse(apiToken db.ApiToken) APITokenResponse {
+	response := APITokenResponse{
+		ID:        apiToken.ID,
+		Name:      apiToken.Name,
+		Prefix:    token.APITokenPrefix + apiToken.Prefix,
+		Scopes:    apiToken.Scopes,
+		CreatedAt: apiToken.CreatedAt,
+	}
+	if apiToken.LastUsedAt.Valid {
+		response.LastUsedAt = &apiToken.LastUsedAt.Time
+	}
+	if apiToken.ExpiresAt.Valid {
+		response.ExpiresAt = &apiToken.ExpiresAt.Time
+	}
+	return response
+}
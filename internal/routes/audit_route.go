@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"image-sharing/internal/audit"
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+)
+
+const (
+	standartAuditLimit = 100
+	maxAuditLimit      = 1000
+)
+
+type AuditEventResponse struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *int32          `json:"actor_id,omitempty"`
+	ActorLogin string          `json:"actor_login,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Outcome    string          `json:"outcome"`
+	Details    json.RawMessage `json:"details"`
+}
+
+type AuditEventsResponse struct {
+	Events []AuditEventResponse `json:"events"`
+	// NextBeforeID is passed as before_id to fetch the next page.
+	NextBeforeID int64 `json:"next_before_id,omitempty"`
+}
+
+type AuditRoute struct {
+	audit *audit.Service
+}
+
+func NewAuditRoute(auditService *audit.Service) *AuditRoute {
+	return &AuditRoute{audit: auditService}
+}
+
+// ListEvents returns events newest first. With format=jsonl every matching event
+// is streamed as one JSON object per line, which is meant for exports.
+func (a *AuditRoute) ListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "jsonl" {
+		a.exportEvents(w, r, filter)
+		return
+	}
+
+	events, err := a.audit.List(r.Context(), filter)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	response := AuditEventsResponse{Events: make([]AuditEventResponse, len(events))}
+	for i, event := range events {
+		response.Events[i] = auditEventToResponse(event)
+	}
+	if len(events) == int(filter.MaxEvents) {
+		response.NextBeforeID = events[len(events)-1].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *AuditRoute) exportEvents(w http.ResponseWriter, r *http.Request, filter db.ListAuditEventsParams) {
+	remaining := -1
+	if r.URL.Query().Get("limit") != "" {
+		remaining = int(filter.MaxEvents)
+	}
+	filter.MaxEvents = maxAuditLimit
+
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	written := 0
+	for remaining != 0 {
+		if remaining > 0 && remaining < int(filter.MaxEvents) {
+			filter.MaxEvents = int32(remaining)
+		}
+		events, err := a.audit.List(r.Context(), filter)
+		if err != nil {
+			if written == 0 {
+				serverError(w, r, err)
+				return
+			}
+			// Events were already sent, all we can do is cut the stream short.
+			logging.FromContext(r.Context()).Error("audit export failed", "events_written", written, "error", err)
+			return
+		}
+		if written == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		}
+		for _, event := range events {
+			if err := encoder.Encode(auditEventToResponse(event)); err != nil {
+				return
+			}
+		}
+		written += len(events)
+		if len(events) < int(filter.MaxEvents) {
+			return
+		}
+		if remaining > 0 {
+			remaining -= len(events)
+		}
+		filter.BeforeID = sql.NullInt64{Int64: events[len(events)-1].ID, Valid: true}
+		controller.Flush()
+	}
+}
+
+func parseAuditFilter(r *http.Request) (db.ListAuditEventsParams, error) {
+	query := r.URL.Query()
+	filter := db.ListAuditEventsParams{
+		Action:     audit.NullString(query.Get("action")),
+		Outcome:    audit.NullString(query.Get("outcome")),
+		TargetType: audit.NullString(query.Get("target_type")),
+		TargetID:   audit.NullString(query.Get("target_id")),
+		MaxEvents:  standartAuditLimit,
+	}
+
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, errInvalidParam("actor_id")
+		}
+		filter.ActorID = sql.NullInt32{Int32: int32(actorID), Valid: true}
+	}
+	if value := query.Get("before_id"); value != "" {
+		beforeID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, errInvalidParam("before_id")
+		}
+		filter.BeforeID = sql.NullInt64{Int64: beforeID, Valid: true}
+	}
+	for name, target := range map[string]*sql.NullTime{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, errInvalidParam(name)
+			}
+			*target = sql.NullTime{Time: parsed, Valid: true}
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return filter, errInvalidParam("limit")
+		}
+		filter.MaxEvents = int32(limit)
+	}
+	return filter, nil
+}
+
+func auditEventToResponse(event db.AuditEvent) AuditEventResponse {
+	response := AuditEventResponse{
+		ID:         event.ID,
+		CreatedAt:  event.CreatedAt,
+		ActorLogin: event.ActorLogin.String,
+		Action:     event.Action,
+		TargetType: event.TargetType.String,
+		TargetID:   event.TargetID.String,
+		IP:         event.Ip.String,
+		UserAgent:  event.UserAgent.String,
+		Outcome:    event.Outcome,
+		Details:    event.Details,
+	}
+	if event.ActorID.Valid {
+		response.ActorID = &event.ActorID.Int32
+	}
+	return response
+}
+
+type errInvalidParam string
+
+func (e errInvalidParam) Error() string {
+	return "invalid " + string(e)
+}
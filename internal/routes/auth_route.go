@@ -3,28 +3,39 @@ package routes
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"image-sharing/internal/audit"
+	"image-sharing/internal/bruteforce"
 	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/metrics"
 	"image-sharing/internal/middleware"
 	"image-sharing/internal/repository"
 	"image-sharing/pkg/password"
 	"image-sharing/pkg/token"
 )
 
-const AccessTokenDuration = 15 * time.Minute
-const RefreshTokenDuration = 24 * time.Hour
+type TokenDurations struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
 
 type LoginRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	// UseCookies starts a browser session: tokens are set as HttpOnly cookies instead of being returned.
+	UseCookies bool `json:"use_cookies"`
 }
 type LoginResponse struct {
 	SessionID             string    `json:"session_id"`
-	AccessToken           string    `json:"access_token"`
-	RefreshToken          string    `json:"refresh_token"`
+	AccessToken           string    `json:"access_token,omitempty"`
+	RefreshToken          string    `json:"refresh_token,omitempty"`
+	CSRFToken             string    `json:"csrf_token,omitempty"`
 	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
 	RefreshTokenExpiresAt time.Time `json:"refrsh_token_expires_at"`
 	Login                 string    `json:"login"`
@@ -34,17 +45,41 @@ type RenewAccessTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 type RenewAccessTokenResponse struct {
-	AccessToken          string    `json:"access_token"`
+	AccessToken          string    `json:"access_token,omitempty"`
 	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
 }
 
+const errInvalidCredentials = "invalid login or password"
+
 type AuthRoute struct {
 	repo       repository.AuthRepository
-	tokenMaker *token.JWTMaker
+	tokenMaker token.Maker
+	durations  TokenDurations
+	tracker    *bruteforce.Tracker
+	hasher     *password.Hasher
+	cookies    middleware.CookieConfig
+	audit      *audit.Service
+	metrics    *metrics.Business
+	// dummyHash is checked for unknown logins so they take as long as wrong passwords.
+	dummyHash string
 }
 
-func NewAuthRoute(repo repository.AuthRepository, tokenMaker *token.JWTMaker) *AuthRoute {
-	return &AuthRoute{repo: repo, tokenMaker: tokenMaker}
+func NewAuthRoute(repo repository.AuthRepository, tokenMaker token.Maker, durations TokenDurations, tracker *bruteforce.Tracker, hasher *password.Hasher, cookies middleware.CookieConfig, auditService *audit.Service, businessMetrics *metrics.Business) (*AuthRoute, error) {
+	dummyHash, err := hasher.Hash("dummy password")
+	if err != nil {
+		return nil, err
+	}
+	return &AuthRoute{
+		repo:       repo,
+		tokenMaker: tokenMaker,
+		durations:  durations,
+		tracker:    tracker,
+		hasher:     hasher,
+		cookies:    cookies,
+		audit:      auditService,
+		metrics:    businessMetrics,
+		dummyHash:  dummyHash,
+	}, nil
 }
 
 func (a *AuthRoute) LoginUser(w http.ResponseWriter, r *http.Request) {
@@ -59,31 +94,64 @@ func (a *AuthRoute) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userAuth, err := a.repo.GetUserAuth(ctx, user.Login)
-	if err != nil {
-		if err == repository.ErrNotFound {
-			http.Error(w, "user not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	attempts := map[bruteforce.Kind]string{bruteforce.KindLogin: user.Login, bruteforce.KindIP: middleware.ClientIP(r)}
+	for kind, value := range attempts {
+		if err := a.tracker.Check(ctx, kind, value); err != nil {
+			a.audit.Record(r, audit.Event{
+				ActorLogin: user.Login,
+				Action:     audit.ActionLogin,
+				Outcome:    audit.OutcomeFailure,
+				Details:    map[string]any{"reason": "blocked", "key": bruteforce.Key(kind, value)},
+			})
+			a.metrics.Login(metrics.LoginBlocked)
+			writeAttemptError(w, r, err)
+			return
 		}
+	}
+
+	userAuth, err := a.repo.GetUserAuth(ctx, user.Login)
+	if err != nil && err != repository.ErrNotFound {
+		serverError(w, r, err)
 		return
 	}
+	found := err == nil
+	if !found {
+		userAuth.PasswordHash = a.dummyHash
+	}
 
 	err = password.CheckPasswrod(user.Password, userAuth.PasswordHash)
-	if err != nil {
-		http.Error(w, "wrong password", http.StatusUnauthorized)
+	if err != nil || !found {
+		for kind, value := range attempts {
+			recordAttempt(r, a.tracker, a.audit, kind, value)
+		}
+		a.audit.Record(r, audit.Event{
+			ActorID:    userAuth.UserID,
+			ActorLogin: user.Login,
+			Action:     audit.ActionLogin,
+			Outcome:    audit.OutcomeFailure,
+			Details:    map[string]any{"reason": "invalid_credentials"},
+		})
+		a.metrics.Login(metrics.LoginFailure)
+		http.Error(w, errInvalidCredentials, http.StatusUnauthorized)
 		return
 	}
+	if err = a.tracker.Reset(ctx, bruteforce.KindLogin, user.Login); err != nil {
+		logging.FromContext(ctx).Error("failed to reset login attempts", "error", err)
+	}
+
+	if a.hasher.NeedsRehash(userAuth.PasswordHash) {
+		a.rehashPassword(r, userAuth, user.Password)
+	}
 
-	accessToken, accessClaims, err := a.tokenMaker.CreateToken(userAuth.UserID, userAuth.Login, userAuth.IsAdmin.Bool, AccessTokenDuration)
+	accessToken, accessClaims, err := a.tokenMaker.CreateToken(userAuth.UserID, userAuth.Login, a.durations.Access)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	refreshToken, refreshClaims, err := a.tokenMaker.CreateToken(userAuth.UserID, userAuth.Login, userAuth.IsAdmin.Bool, RefreshTokenDuration)
+	refreshToken, refreshClaims, err := a.tokenMaker.CreateToken(userAuth.UserID, userAuth.Login, a.durations.Refresh)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -96,68 +164,138 @@ func (a *AuthRoute) LoginUser(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt:    sql.NullTime{Time: refreshClaims.RegisteredClaims.ExpiresAt.Time, Valid: true},
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{
+	a.audit.Record(r, audit.Event{
+		ActorID:    userAuth.UserID,
+		ActorLogin: userAuth.Login,
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetSession,
+		TargetID:   session.ID,
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"cookies": user.UseCookies},
+	})
+	a.metrics.Login(metrics.LoginSuccess)
+
+	response := LoginResponse{
 		SessionID:             session.ID,
 		AccessToken:           accessToken,
 		RefreshToken:          refreshToken,
 		AccessTokenExpiresAt:  accessClaims.RegisteredClaims.ExpiresAt.Time,
 		RefreshTokenExpiresAt: refreshClaims.RegisteredClaims.ExpiresAt.Time,
-		Login:                 userAuth.Login})
+		Login:                 userAuth.Login}
+
+	if user.UseCookies {
+		response.CSRFToken, err = a.cookies.SetSessionCookies(w,
+			accessToken, response.AccessTokenExpiresAt,
+			refreshToken, response.RefreshTokenExpiresAt)
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+		response.AccessToken = ""
+		response.RefreshToken = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 func (a *AuthRoute) RenewAccessToken(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var req RenewAccessTokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
 		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
+
+	fromCookie := false
 	if req.RefreshToken == "" {
-		http.Error(w, "access_token required", http.StatusBadRequest)
+		if cookie, err := r.Cookie(middleware.RefreshTokenCookie); err == nil && cookie.Value != "" {
+			if err = middleware.CheckCSRF(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			req.RefreshToken = cookie.Value
+			fromCookie = true
+		}
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh_token required", http.StatusBadRequest)
 		return
 	}
 
 	refreshClaims, err := a.tokenMaker.VerifyToken(req.RefreshToken)
 	if err != nil {
+		a.audit.Record(r, audit.Event{Action: audit.ActionTokenRenew, Outcome: audit.OutcomeFailure, Details: map[string]any{"reason": "invalid_token"}})
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	renewEvent := audit.Event{
+		ActorID:    refreshClaims.ID,
+		ActorLogin: refreshClaims.Login,
+		Action:     audit.ActionTokenRenew,
+		TargetType: audit.TargetSession,
+		TargetID:   refreshClaims.RegisteredClaims.ID,
+		Outcome:    audit.OutcomeFailure,
+	}
 
 	session, err := a.repo.GetSessionByID(ctx, refreshClaims.RegisteredClaims.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	if session.IsRevoked {
+		renewEvent.Details = map[string]any{"reason": "session_revoked"}
+		a.audit.Record(r, renewEvent)
 		http.Error(w, "session revoked", http.StatusUnauthorized)
 		return
 	}
 
 	if session.UserLogin != refreshClaims.Login {
+		renewEvent.Details = map[string]any{"reason": "login_mismatch"}
+		a.audit.Record(r, renewEvent)
 		http.Error(w, "invaild session", http.StatusUnauthorized)
 		return
 	}
 
-	accessToken, accessClaims, err := a.tokenMaker.CreateToken(refreshClaims.ID, refreshClaims.Login, refreshClaims.IsAdmin, AccessTokenDuration)
+	accessToken, accessClaims, err := a.tokenMaker.CreateToken(refreshClaims.ID, refreshClaims.Login, a.durations.Access)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	err = a.repo.RenewSession(ctx, session.ID, accessToken)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	renewEvent.Outcome = audit.OutcomeSuccess
+	a.audit.Record(r, renewEvent)
+
+	response := RenewAccessTokenResponse{AccessToken: accessToken, AccessTokenExpiresAt: accessClaims.RegisteredClaims.ExpiresAt.Time}
+	if fromCookie {
+		a.cookies.SetAccessCookie(w, accessToken, response.AccessTokenExpiresAt)
+		response.AccessToken = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *AuthRoute) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	keySet := token.JWKSet{Keys: []token.JWK{}}
+	if publisher, ok := a.tokenMaker.(token.KeySetPublisher); ok {
+		keySet = publisher.JWKS()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(RenewAccessTokenResponse{AccessToken: accessToken, AccessTokenExpiresAt: accessClaims.RegisteredClaims.ExpiresAt.Time})
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keySet)
 }
 
 func (a *AuthRoute) LogoutUser(w http.ResponseWriter, r *http.Request) {
@@ -165,21 +303,80 @@ func (a *AuthRoute) LogoutUser(w http.ResponseWriter, r *http.Request) {
 
 	err := a.repo.RevokeSession(r.Context(), claims.AccesToken)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	a.audit.Record(r, audit.Event{Action: audit.ActionLogout, Outcome: audit.OutcomeSuccess})
+	a.cookies.ClearSessionCookies(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (a *AuthRoute) RevokeSessions(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(middleware.AuthKey{}).(*middleware.AccessClaims)
-	fmt.Println(claims.Login)
 	err := a.repo.RevokeAllSessions(r.Context(), claims.Login)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	a.audit.Record(r, audit.Event{Action: audit.ActionSessionsRevoke, TargetType: audit.TargetUser, TargetID: strconv.Itoa(int(claims.ID)), Outcome: audit.OutcomeSuccess})
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// rehashPassword upgrades the stored hash to the preferred algorithm. Failing here must not fail the login.
+func (a *AuthRoute) rehashPassword(r *http.Request, user db.UsersAuth, plain string) {
+	ctx := r.Context()
+	hash, err := a.hasher.Hash(plain)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to rehash password", "user_id", user.UserID, "error", err)
+		return
+	}
+	event := audit.Event{
+		ActorID:    user.UserID,
+		ActorLogin: user.Login,
+		Action:     audit.ActionPasswordChange,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.UserID)),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"reason": "rehash"},
+	}
+	if err = a.repo.UpdatePasswordHash(ctx, user.UserID, hash); err != nil {
+		logging.FromContext(ctx).Error("failed to store rehashed password", "user_id", user.UserID, "error", err)
+		event.Outcome = audit.OutcomeFailure
+	}
+	a.audit.Record(r, event)
+}
+
+// recordAttempt counts a failed attempt and audits the lockout it may have caused.
+func recordAttempt(r *http.Request, tracker *bruteforce.Tracker, auditService *audit.Service, kind bruteforce.Kind, value string) {
+	err := tracker.Record(r.Context(), kind, value)
+	if err == nil {
+		return
+	}
+	blocked, ok := err.(*bruteforce.BlockedError)
+	if !ok {
+		logging.FromContext(r.Context()).Error("failed to record attempt", "kind", kind, "error", err)
+		return
+	}
+	if blocked.NewLockout {
+		auditService.Record(r, audit.Event{
+			Action:     audit.ActionLockout,
+			TargetType: audit.TargetLockout,
+			TargetID:   bruteforce.Key(kind, value),
+			Outcome:    audit.OutcomeSuccess,
+			Details:    map[string]any{"duration_seconds": blocked.RetryAfter.Seconds()},
+		})
+	}
+}
+
+// writeAttemptError answers with 429 and Retry-After when the tracker blocked the request.
+func writeAttemptError(w http.ResponseWriter, r *http.Request, err error) {
+	blocked, ok := err.(*bruteforce.BlockedError)
+	if !ok {
+		serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	http.Error(w, blocked.Error(), http.StatusTooManyRequests)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+
+	"image-sharing/internal/logging"
+)
+
+// serverError logs err with the request context and hides it from the client.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("request failed", "error", err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"image-sharing/internal/audit"
+	"image-sharing/internal/bruteforce"
+)
+
+type LockoutResponse struct {
+	Key          string    `json:"key"`
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	BlockedUntil time.Time `json:"blocked_until"`
+}
+
+type LockoutRoute struct {
+	tracker *bruteforce.Tracker
+	audit   *audit.Service
+}
+
+func NewLockoutRoute(tracker *bruteforce.Tracker, auditService *audit.Service) *LockoutRoute {
+	return &LockoutRoute{tracker: tracker, audit: auditService}
+}
+
+func (l *LockoutRoute) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	entries, err := l.tracker.ListLocked(r.Context())
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	result := make([]LockoutResponse, len(entries))
+	for i, entry := range entries {
+		result[i] = LockoutResponse{
+			Key:          entry.Key,
+			Failures:     entry.Failures,
+			LastFailure:  entry.LastFailure,
+			BlockedUntil: entry.BlockedUntil,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (l *LockoutRoute) Unlock(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	unlocked, err := l.tracker.Unlock(r.Context(), key)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if !unlocked {
+		http.Error(w, "lockout not found", http.StatusNotFound)
+		return
+	}
+	l.audit.Record(r, audit.Event{Action: audit.ActionUnlock, TargetType: audit.TargetLockout, TargetID: key, Outcome: audit.OutcomeSuccess})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,24 +10,41 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"image-sharing/internal/audit"
+	"image-sharing/internal/configs"
 	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/media"
+	"image-sharing/internal/metrics"
+	"image-sharing/internal/quota"
+	"image-sharing/internal/rbac"
 	"image-sharing/internal/repository"
+	"image-sharing/internal/tracing"
+	"image-sharing/pkg/token"
 
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-const maxUploadSize = 500 << 20
 const standartPostLimit = 10
 const minLimit = 5
 const maxLimit = 50
 
-var allowedFileFormats = map[string]string{"image/png": ".png", "image/jpeg": ".jpeg", "image/gif": ".gif", "video/mp4": ".mp4", "video/webm": ".webm"}
-
 type PostResponse struct {
-	ID       int32  `json:"post_id"`
-	UserID   int32  `json:"user_id"`
-	UserName string `json:"user_name"`
+	ID          int32  `json:"post_id"`
+	UserID      int32  `json:"user_id"`
+	UserName    string `json:"user_name"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+}
+type TrashedPostResponse struct {
+	ID          int32     `json:"post_id"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	PurgeAt     time.Time `json:"purge_at"`
 }
 type PaginatedPostResponse struct {
 	TotalCount int            `json:"total_count"`
@@ -36,11 +55,26 @@ type PaginatedPostResponse struct {
 }
 
 type PostRoute struct {
-	repo repository.PostRepository
+	repo           repository.PostRepository
+	audit          *audit.Service
+	maxUploadSize  int64
+	allowedFormats map[string]string
+	metrics        *metrics.Business
+	trashRetention time.Duration
+	// stripMetadata removes EXIF and similar metadata from images, owners may
+	// keep the original EXIF privately when allowKeepMetadata is set.
+	stripMetadata     bool
+	allowKeepMetadata bool
+	// mediaLimits bound the decoding of uploaded images.
+	mediaLimits media.Limits
 }
 
-func NewPostRoute(repo repository.PostRepository) *PostRoute {
-	return &PostRoute{repo: repo}
+func NewPostRoute(repo repository.PostRepository, auditService *audit.Service, maxUploadSize int64, allowedFormats []string, businessMetrics *metrics.Business, trashRetention time.Duration, stripMetadata bool, allowKeepMetadata bool, mediaLimits media.Limits) *PostRoute {
+	formats := make(map[string]string, len(allowedFormats))
+	for _, format := range allowedFormats {
+		formats[format] = configs.FileFormats[format]
+	}
+	return &PostRoute{repo: repo, audit: auditService, maxUploadSize: maxUploadSize, allowedFormats: formats, metrics: businessMetrics, trashRetention: trashRetention, stripMetadata: stripMetadata, allowKeepMetadata: allowKeepMetadata, mediaLimits: mediaLimits}
 }
 
 func (p *PostRoute) GetPost(w http.ResponseWriter, r *http.Request) {
@@ -54,13 +88,56 @@ func (p *PostRoute) GetPost(w http.ResponseWriter, r *http.Request) {
 		if err == repository.ErrNotFound {
 			http.Error(w, "user not found", http.StatusNotFound)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			serverError(w, r, err)
 		}
 		return
 	}
 	http.ServeFile(w, r, post.ImagePath)
 }
 
+// GetPostMetadata returns the original EXIF the owner chose to keep when the
+// image was uploaded. Nobody else can read it.
+func (p *PostRoute) GetPostMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !claims.HasScope(token.ScopePostsRead) {
+		http.Error(w, "missing scope: "+token.ScopePostsRead, http.StatusForbidden)
+		return
+	}
+
+	// Posts of other users are reported as missing, like posts without metadata.
+	userID, err := p.repo.GetPostUserID(ctx, id)
+	if err == nil && userID != claims.ID {
+		err = repository.ErrNotFound
+	}
+	var exif []byte
+	if err == nil {
+		exif, err = p.repo.GetPostMetadata(ctx, id)
+	}
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "metadata not found", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.exif\"", id))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(exif)
+}
+
 func (p *PostRoute) GetPosts(w http.ResponseWriter, r *http.Request) {
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
@@ -86,16 +163,18 @@ func (p *PostRoute) GetPosts(w http.ResponseWriter, r *http.Request) {
 
 	posts, count, err := p.repo.GetAllPosts(r.Context(), page, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		serverError(w, r, err)
 		return
 	}
 
 	result := make([]PostResponse, len(posts))
 	for i, j := range posts {
 		result[i] = PostResponse{
-			ID:       j.ID,
-			UserID:   j.UserID,
-			UserName: j.UserName,
+			ID:          j.ID,
+			UserID:      j.UserID,
+			UserName:    j.UserName,
+			ContentType: j.ContentType,
+			SizeBytes:   j.SizeBytes,
 		}
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -115,27 +194,43 @@ func (p *PostRoute) CreatePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !claims.HasScope(token.ScopePostsWrite) {
+		http.Error(w, "missing scope: "+token.ScopePostsWrite, http.StatusForbidden)
 		return
 	}
-	file, _, err := r.FormFile("post")
+
+	keepMetadata := false
+	if value := r.URL.Query().Get("keep_metadata"); value != "" {
+		keepMetadata, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "invalid keep_metadata", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The body is streamed part by part instead of being spooled by
+	// ParseMultipartForm, so only the file itself is ever written to disk.
+	r.Body = http.MaxBytesReader(w, r.Body, p.maxUploadSize)
+	reader, err := r.MultipartReader()
 	if err != nil {
+		p.metrics.UploadRejected(metrics.RejectInvalidForm)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
-
-	format, err := isAllowedFileFormat(file)
+	part, err := nextFilePart(reader, "post")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		p.rejectUpload(w, err)
 		return
 	}
+	defer part.Close()
 
-	post, err := p.repo.CreatePost(ctx, db.CreatePostParams{UserID: claims.ID, ImagePath: ""}, file, format)
+	post, err := p.publish(ctx, claims.ID, part, keepMetadata, "")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if isRejectedUpload(err) {
+			p.rejectUpload(w, err)
+			return
+		}
+		serverError(w, r, err)
 		return
 	}
 
@@ -156,50 +251,294 @@ func (p *PostRoute) DeletePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
+	if !claims.HasScope(token.ScopePostsWrite) {
+		http.Error(w, "missing scope: "+token.ScopePostsWrite, http.StatusForbidden)
+		return
+	}
 
 	userID, err := p.repo.GetPostUserID(ctx, id)
 	if err != nil {
 		if err == repository.ErrNotFound {
 			http.Error(w, "post not found", http.StatusNotFound)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			serverError(w, r, err)
 		}
 		return
 	}
 
-	if !claims.IsAdmin && claims.ID != userID {
+	if claims.ID != userID && !claims.HasPermission(rbac.PostDeleteAny) {
 		http.Error(w, "not an owner", http.StatusForbidden)
 		return
 	}
 
-	err = p.repo.DeletePost(ctx, id)
+	err = p.repo.TrashPost(ctx, id)
 	if err != nil {
 		if err == repository.ErrNotFound {
 			http.Error(w, "post not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
+	p.audit.Record(r, audit.Event{
+		Action:     audit.ActionPostDelete,
+		TargetType: audit.TargetPost,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"owner_id": userID, "by_owner": claims.ID == userID},
+	})
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("post deleted"))
+	w.Write([]byte("post moved to trash"))
+}
+
+// GetTrash lists the posts the caller deleted and when they will be purged.
+func (p *PostRoute) GetTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !claims.HasScope(token.ScopePostsRead) {
+		http.Error(w, "missing scope: "+token.ScopePostsRead, http.StatusForbidden)
+		return
+	}
+
+	posts, err := p.repo.ListTrashedPosts(ctx, claims.ID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	result := make([]TrashedPostResponse, len(posts))
+	for i, post := range posts {
+		result[i] = TrashedPostResponse{
+			ID:          post.ID,
+			ContentType: post.ContentType,
+			SizeBytes:   post.SizeBytes,
+			DeletedAt:   post.DeletedAt.Time,
+			PurgeAt:     post.DeletedAt.Time.Add(p.trashRetention),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (p *PostRoute) RestorePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !claims.HasScope(token.ScopePostsWrite) {
+		http.Error(w, "missing scope: "+token.ScopePostsWrite, http.StatusForbidden)
+		return
+	}
+
+	userID, err := p.repo.GetTrashedPostUserID(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "post not found in trash", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+
+	if claims.ID != userID && !claims.HasPermission(rbac.PostDeleteAny) {
+		http.Error(w, "not an owner", http.StatusForbidden)
+		return
+	}
+
+	err = p.repo.RestorePost(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "post not found in trash", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+	p.audit.Record(r, audit.Event{
+		Action:     audit.ActionPostRestore,
+		TargetType: audit.TargetPost,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"owner_id": userID, "by_owner": claims.ID == userID},
+	})
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("post restored"))
 }
 
-func isAllowedFileFormat(file multipart.File) (string, error) {
-	buffer := make([]byte, 512)
-	if _, err := file.Read(buffer); err != nil {
-		return "", err
+// PurgePost permanently deletes a post, skipping the trash. Access is checked
+// by the router with the post.purge permission.
+func (p *PostRoute) PurgePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
 	}
 
-	_, err := file.Seek(0, io.SeekStart)
+	post, err := p.repo.DeletePost(r.Context(), id)
 	if err != nil {
-		return "", err
+		if err == repository.ErrNotFound {
+			http.Error(w, "post not found", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
 	}
+	p.audit.Record(r, audit.Event{
+		Action:     audit.ActionPostPurge,
+		TargetType: audit.TargetPost,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"owner_id": post.UserID, "trashed": post.DeletedAt.Valid},
+	})
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("post deleted"))
+}
+
+// sniffLen is how much of the upload http.DetectContentType looks at.
+const sniffLen = 512
+
+var errMissingFile = errors.New("missing file")
+var errEmptyFile = errors.New("empty file")
+var errFileFormat = errors.New("not allowed file format")
+
+// nextFilePart skips to the form part called name.
+func nextFilePart(reader *multipart.Reader, name string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errMissingFile
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name {
+			return part, nil
+		}
+		part.Close()
+	}
+}
 
-	contentType := http.DetectContentType(buffer)
-	format, ok := allowedFileFormats[contentType]
+// isAllowedFileFormat sniffs the first bytes of media without consuming them,
+// so an upload is rejected before anything is written to storage.
+func isAllowedFileFormat(ctx context.Context, media *bufio.Reader, allowedFormats map[string]string) (contentType string, format string, err error) {
+	_, span := tracing.Start(ctx, "media.detect_format")
+	defer func() { tracing.End(span, err) }()
+
+	head, err := media.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+	if len(head) == 0 {
+		return "", "", errEmptyFile
+	}
+
+	contentType = http.DetectContentType(head)
+	span.SetAttributes(attribute.String("media.content_type", contentType))
+	format, ok := allowedFormats[contentType]
 	if !ok {
-		return "", errors.New("not allowed file format")
+		return "", "", errFileFormat
+	}
+	return contentType, format, nil
+}
+
+// publish validates file and stores it as a post of userID. Direct and
+// resumable uploads both go through here. The whole file is checked, not just
+// its magic bytes: images are decoded and videos have their container walked.
+// Image metadata is stripped on the way to storage, keepMetadata asks to keep
+// the original EXIF for the owner. uploadID names the resumable upload the
+// file comes from, it is empty for direct uploads.
+func (p *PostRoute) publish(ctx context.Context, userID int32, file io.Reader, keepMetadata bool, uploadID string) (db.Post, error) {
+	buffered := bufio.NewReaderSize(file, sniffLen)
+	contentType, format, err := isAllowedFileFormat(ctx, buffered, p.allowedFormats)
+	if err != nil {
+		return db.Post{}, err
+	}
+
+	var content io.Reader = buffered
+	if p.stripMetadata && media.Supports(format) {
+		// Data after the end of the image, such as the extra images of MPO
+		// files and motion photos, is dropped here rather than rejected.
+		sanitizer := media.Sanitize(buffered, format, keepMetadata && p.allowKeepMetadata)
+		defer sanitizer.Close()
+		content = sanitizer
+	}
+	// What is stored is validated, nothing is kept unless all of it is valid.
+	validator := media.Validate(content, format, p.mediaLimits)
+	defer validator.Close()
+
+	post, err := p.repo.CreatePost(ctx, db.CreatePostParams{UserID: userID, ContentType: contentType}, validator, format, uploadID)
+	if err != nil {
+		return db.Post{}, err
+	}
+	p.metrics.UploadAccepted(strings.TrimPrefix(format, "."), post.SizeBytes)
+	return post, nil
+}
+
+// isRejectedUpload reports whether err was caused by the upload itself rather than the server.
+func isRejectedUpload(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return err == errFileFormat || err == errEmptyFile || media.IsInvalid(err) || quota.IsExceeded(err) ||
+		errors.As(err, &maxBytesErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, multipart.ErrMessageTooLarge)
+}
+
+// UploadRejection tells a client why its upload was refused. Reason is
+// stable and meant for programs, Error for people.
+type UploadRejection struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+	Limit  string `json:"limit,omitempty"`
+}
+
+// rejectUpload answers a failed upload caused by the client and counts it by reason.
+func (p *PostRoute) rejectUpload(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var quotaErr *quota.ExceededError
+	var mediaErr *media.ValidationError
+	rejection := UploadRejection{Error: err.Error(), Reason: metrics.RejectInvalidForm}
+	status := http.StatusBadRequest
+	switch {
+	case errors.As(err, &quotaErr):
+		rejection.Reason, rejection.Limit = metrics.RejectQuota, quotaErr.Limit
+		status = quotaStatus(quotaErr.Limit)
+	case errors.As(err, &maxBytesErr):
+		rejection.Error, rejection.Reason = "file is too large", metrics.RejectTooLarge
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &mediaErr):
+		// Media reasons are a small fixed set, they label the metric as well.
+		rejection.Reason = mediaErr.Reason
+	case err == errMissingFile:
+		rejection.Reason = metrics.RejectMissingFile
+	case err == errEmptyFile:
+		rejection.Reason = metrics.RejectEmptyFile
+	case err == errFileFormat:
+		rejection.Reason = metrics.RejectFormat
+	}
+	p.metrics.UploadRejected(rejection.Reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rejection)
+}
+
+// quotaStatus is the response code for an upload going over limit.
+func quotaStatus(limit string) int {
+	switch limit {
+	case quota.LimitFileSize, quota.LimitBytes:
+		return http.StatusRequestEntityTooLarge
+	case quota.LimitUploadsPerDay:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusForbidden
 	}
-	return format, nil
 }
@@ -0,0 +1,228 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"image-sharing/internal/audit"
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/quota"
+	"image-sharing/internal/repository"
+	"image-sharing/pkg/token"
+)
+
+// QuotaRequest overrides the defaults for a user. Omitted or null fields keep
+// the configured default, 0 removes the limit.
+type QuotaRequest struct {
+	MaxBytes         *int64 `json:"max_bytes"`
+	MaxPosts         *int64 `json:"max_posts"`
+	MaxFileSize      *int64 `json:"max_file_size"`
+	MaxUploadsPerDay *int64 `json:"max_uploads_per_day"`
+}
+
+type QuotaLimitsResponse struct {
+	MaxBytes         int64 `json:"max_bytes"`
+	MaxPosts         int64 `json:"max_posts"`
+	MaxFileSize      int64 `json:"max_file_size"`
+	MaxUploadsPerDay int64 `json:"max_uploads_per_day"`
+}
+
+type QuotaUsageResponse struct {
+	Bytes        int64 `json:"bytes"`
+	Posts        int64 `json:"posts"`
+	UploadsToday int64 `json:"uploads_today"`
+	PendingBytes int64 `json:"pending_bytes"`
+}
+
+type UsageResponse struct {
+	Limits QuotaLimitsResponse `json:"limits"`
+	Usage  QuotaUsageResponse  `json:"usage"`
+}
+
+type UserQuotaResponse struct {
+	UsageResponse
+	Defaults QuotaLimitsResponse `json:"defaults"`
+	Override *QuotaRequest       `json:"override"`
+}
+
+type QuotaRoute struct {
+	repo  repository.QuotaRepository
+	audit *audit.Service
+}
+
+func NewQuotaRoute(repo repository.QuotaRepository, auditService *audit.Service) *QuotaRoute {
+	return &QuotaRoute{repo: repo, audit: auditService}
+}
+
+// GetUsage reports the quota of the caller and how much of it is used.
+func (q *QuotaRoute) GetUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if !claims.HasScope(token.ScopePostsRead) {
+		http.Error(w, "missing scope: "+token.ScopePostsRead, http.StatusForbidden)
+		return
+	}
+
+	usage, err := q.usage(r, claims.ID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+func (q *QuotaRoute) GetUserQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := q.usage(r, int32(id))
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	result := UserQuotaResponse{UsageResponse: usage, Defaults: limitsToResponse(q.repo.Defaults())}
+	override, err := q.repo.GetOverride(r.Context(), int32(id))
+	if err == nil {
+		result.Override = overrideToResponse(override)
+	} else if err != repository.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (q *QuotaRoute) SetUserQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req QuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := db.SetUserQuotaParams{
+		UserID:           int32(id),
+		MaxBytes:         nullLimit(req.MaxBytes),
+		MaxPosts:         nullLimit(req.MaxPosts),
+		MaxFileSize:      nullLimit(req.MaxFileSize),
+		MaxUploadsPerDay: nullLimit(req.MaxUploadsPerDay),
+	}
+	for _, limit := range []sql.NullInt64{params.MaxBytes, params.MaxPosts, params.MaxFileSize, params.MaxUploadsPerDay} {
+		if limit.Int64 < 0 {
+			http.Error(w, "limits must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	override, err := q.repo.SetOverride(r.Context(), params)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "user not found", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+	q.audit.Record(r, audit.Event{
+		Action:     audit.ActionQuotaSet,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"override": overrideToResponse(override)},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overrideToResponse(override))
+}
+
+func (q *QuotaRoute) DeleteUserQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	err = q.repo.DeleteOverride(r.Context(), int32(id))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "quota not overridden", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+	q.audit.Record(r, audit.Event{
+		Action:     audit.ActionQuotaReset,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (q *QuotaRoute) usage(r *http.Request, userID int32) (UsageResponse, error) {
+	limits, err := q.repo.GetLimits(r.Context(), userID)
+	if err != nil {
+		return UsageResponse{}, err
+	}
+	usage, err := q.repo.GetUsage(r.Context(), userID)
+	if err != nil {
+		return UsageResponse{}, err
+	}
+	return UsageResponse{
+		Limits: limitsToResponse(limits),
+		Usage: QuotaUsageResponse{
+			Bytes:        usage.Bytes,
+			Posts:        usage.Posts,
+			UploadsToday: usage.UploadsToday,
+			PendingBytes: usage.PendingBytes,
+		},
+	}, nil
+}
+
+func limitsToResponse(limits quota.Limits) QuotaLimitsResponse {
+	return QuotaLimitsResponse{
+		MaxBytes:         limits.MaxBytes,
+		MaxPosts:         limits.MaxPosts,
+		MaxFileSize:      limits.MaxFileSize,
+		MaxUploadsPerDay: limits.MaxUploadsPerDay,
+	}
+}
+
+func overrideToResponse(override db.UserQuota) *QuotaRequest {
+	return &QuotaRequest{
+		MaxBytes:         limitPointer(override.MaxBytes),
+		MaxPosts:         limitPointer(override.MaxPosts),
+		MaxFileSize:      limitPointer(override.MaxFileSize),
+		MaxUploadsPerDay: limitPointer(override.MaxUploadsPerDay),
+	}
+}
+
+func nullLimit(limit *int64) sql.NullInt64 {
+	if limit == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *limit, Valid: true}
+}
+
+func limitPointer(limit sql.NullInt64) *int64 {
+	if !limit.Valid {
+		return nil
+	}
+	return &limit.Int64
+}
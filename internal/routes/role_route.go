@@ -0,0 +1,240 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"image-sharing/internal/audit"
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/repository"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{1,63}$`)
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type PermissionResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type RoleRoute struct {
+	repo  repository.RBACRepository
+	audit *audit.Service
+}
+
+func NewRoleRoute(repo repository.RBACRepository, auditService *audit.Service) *RoleRoute {
+	return &RoleRoute{repo: repo, audit: auditService}
+}
+
+func (rr *RoleRoute) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := rr.repo.ListPermissions(r.Context())
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	result := make([]PermissionResponse, len(permissions))
+	for i, permission := range permissions {
+		result[i] = PermissionResponse{Name: permission.Name, Description: permission.Description.String}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (rr *RoleRoute) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := rr.repo.ListRoles(r.Context())
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	result := make([]RoleResponse, len(roles))
+	for i, role := range roles {
+		result[i] = roleToResponse(role.Role, role.Permissions)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (rr *RoleRoute) CreateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		http.Error(w, "invalid role name", http.StatusBadRequest)
+		return
+	}
+
+	known, err := rr.repo.ListPermissions(ctx)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	for _, permission := range req.Permissions {
+		if !slices.ContainsFunc(known, func(p db.Permission) bool { return p.Name == permission }) {
+			http.Error(w, "unknown permission: "+permission, http.StatusBadRequest)
+			return
+		}
+	}
+
+	role, err := rr.repo.CreateRole(ctx, db.CreateRoleParams{
+		Name:        req.Name,
+		Description: sql.NullString{String: req.Description, Valid: req.Description != ""},
+	}, req.Permissions)
+	if err != nil {
+		if err == repository.ErrRoleExists {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+
+	rr.audit.Record(r, audit.Event{
+		Action:     audit.ActionRoleCreate,
+		TargetType: audit.TargetRole,
+		TargetID:   role.Name,
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"permissions": role.Permissions},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(roleToResponse(role.Role, role.Permissions))
+}
+
+func (rr *RoleRoute) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "role")
+	err := rr.repo.DeleteRole(r.Context(), name)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			http.Error(w, "role not found", http.StatusNotFound)
+		case repository.ErrBuiltinRole:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, err)
+		}
+		return
+	}
+	rr.audit.Record(r, audit.Event{Action: audit.ActionRoleDelete, TargetType: audit.TargetRole, TargetID: name, Outcome: audit.OutcomeSuccess})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rr *RoleRoute) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	roles, err := rr.repo.ListUserRoles(r.Context(), int32(id))
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	result := make([]RoleResponse, len(roles))
+	for i, role := range roles {
+		result[i] = roleToResponse(role, nil)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (rr *RoleRoute) AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		http.Error(w, "role required", http.StatusBadRequest)
+		return
+	}
+
+	err = rr.repo.AssignUserRole(r.Context(), int32(id), req.Role)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "user or role not found", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+	rr.audit.Record(r, audit.Event{
+		Action:     audit.ActionRoleAssign,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"role": req.Role},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rr *RoleRoute) RemoveUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	role := chi.URLParam(r, "role")
+	err = rr.repo.RemoveUserRole(r.Context(), int32(id), role)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "role not assigned", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return
+	}
+	rr.audit.Record(r, audit.Event{
+		Action:     audit.ActionRoleRemove,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+		Details:    map[string]any{"role": role},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func roleToResponse(role db.Role, permissions []string) RoleResponse {
+	return RoleResponse{
+		Name:        role.Name,
+		Description: role.Description.String,
+		Builtin:     role.IsBuiltin,
+		Permissions: permissions,
+	}
+}
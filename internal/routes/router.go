@@ -1,61 +1,149 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"image-sharing/pkg/password"
 	"image-sharing/pkg/token"
 
+	"image-sharing/internal/audit"
+	"image-sharing/internal/bruteforce"
 	"image-sharing/internal/configs"
 	"image-sharing/internal/db/gen"
+	"image-sharing/internal/media"
 	"image-sharing/internal/metrics"
 	midle "image-sharing/internal/middleware"
+	"image-sharing/internal/quota"
+	"image-sharing/internal/ratelimit"
+	"image-sharing/internal/rbac"
 	"image-sharing/internal/repository"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
 )
 
-func SetupRouter(dbConnetcion *sql.DB, config configs.Config) *chi.Mux {
+func SetupRouter(dbConnetcion *sql.DB, config configs.Config, tokenMaker token.Maker, registry *prometheus.Registry) (*chi.Mux, error) {
 	router := chi.NewRouter()
-	metrics := metrics.New()
-	router.Use(middleware.Logger)
+	httpMetrics := metrics.New(registry)
+	businessMetrics := metrics.NewBusiness(registry)
+	router.Use(tracing.Middleware)
+	router.Use(midle.RequestID)
+	router.Use(midle.Logger(slog.Default()))
 	router.Use(middleware.StripSlashes)
 	router.Use(middleware.Recoverer)
-	router.Use(metrics.Middleware())
+	router.Use(httpMetrics.Middleware())
 
-	querys := db.New(dbConnetcion)
+	querys := db.New(tracing.WrapDB(dbConnetcion))
 
-	tokenMaker := token.NewJWTMaker(config.SecretKey)
+	metrics.RegisterDBStats(registry, dbConnetcion)
+	metrics.RegisterActiveSessions(registry, func(ctx context.Context) (int64, error) {
+		return querys.CountActiveSessions(ctx, time.Now())
+	})
+	metrics.RegisterStorageUsage(registry, config.ImagesDirectory)
+
+	auditService := audit.NewService(querys)
+	auditRoute := NewAuditRoute(auditService)
+
+	apiTokenRepository := repository.NewAPITokenRepository(dbConnetcion, querys)
+	apiTokenRoute := NewAPITokenRoute(apiTokenRepository, auditService)
 
-	authMiddleware := midle.GetAuthMiddleware(tokenMaker)
+	rbacRepository := repository.NewRBACRepository(dbConnetcion, querys)
+	roleRoute := NewRoleRoute(rbacRepository, auditService)
+
+	authMiddleware := midle.GetAuthMiddleware(tokenMaker, apiTokenRepository, rbacRepository)
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.RateLimitStore == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(querys)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore)
+	rateLimit := func(policy ratelimit.Policy, key midle.RateLimitKey) func(http.Handler) http.Handler {
+		if !config.RateLimitEnabled {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		return midle.RateLimit(limiter, policy, key)
+	}
 
 	authRepository := repository.NewAuthRepository(dbConnetcion, querys)
-	authRoute := NewAuthRoute(authRepository, tokenMaker)
+	var attemptStore bruteforce.Store = bruteforce.NewMemoryStore()
+	if config.BruteforceStore == "postgres" {
+		attemptStore = bruteforce.NewPostgresStore(querys)
+	}
+	attemptTracker := bruteforce.NewTracker(attemptStore, bruteforce.DefaultPolicies)
+	lockoutRoute := NewLockoutRoute(attemptTracker, auditService)
+
+	hasher, err := password.NewHasher(config.PasswordAlgorithm, password.Argon2Params{
+		Memory:      config.Argon2Memory,
+		Iterations:  config.Argon2Iterations,
+		Parallelism: config.Argon2Parallelism,
+	}, config.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	passwordPolicy, err := password.NewPolicy(config.PasswordMinLength, config.PasswordBreachList)
+	if err != nil {
+		return nil, err
+	}
+
+	cookies := midle.CookieConfig{
+		Secure:   config.CookieSecure,
+		Domain:   config.CookieDomain,
+		SameSite: config.CookieSameSite,
+	}
+	authRoute, err := NewAuthRoute(authRepository, tokenMaker, TokenDurations{Access: config.AccessTokenDuration, Refresh: config.RefreshTokenDuration}, attemptTracker, hasher, cookies, auditService, businessMetrics)
+	if err != nil {
+		return nil, err
+	}
 
 	uerRepository := repository.NewUserRepository(dbConnetcion, querys)
-	userRoute := NewUserRoute(uerRepository)
+	userRoute := NewUserRoute(uerRepository, attemptTracker, hasher, passwordPolicy, auditService)
+
+	quotaDefaults := quota.Limits{
+		MaxBytes:         config.QuotaMaxBytes,
+		MaxPosts:         config.QuotaMaxPosts,
+		MaxFileSize:      config.QuotaMaxFileSize,
+		MaxUploadsPerDay: config.QuotaMaxUploadsPerDay,
+	}
+	quotaRepository := repository.NewQuotaRepository(dbConnetcion, querys, quotaDefaults)
+	quotaRoute := NewQuotaRoute(quotaRepository, auditService)
 
-	postRepository := repository.NewPostRepository(dbConnetcion, querys, config.ImagesDirectory)
-	postRoute := NewPostRoute(postRepository)
+	store := storage.NewFilesystem(config.ImagesDirectory)
+	postRepository := repository.NewPostRepository(dbConnetcion, querys, store, config.ReuseDuplicatePosts, quotaDefaults)
+	mediaLimits := media.Limits{MaxPixels: config.MaxImagePixels, Memory: media.NewBudget(config.MaxDecodeMemory)}
+	postRoute := NewPostRoute(postRepository, auditService, config.MaxUploadSize, config.AllowedFileFormats, businessMetrics, config.TrashRetention, config.StripMetadata, config.AllowKeepMetadata, mediaLimits)
 
-	router.Get("/metrics", metrics.Handler().ServeHTTP)
+	router.Use(rateLimit(ratelimit.PolicyDefault, midle.RateLimitByIP))
+	router.Get("/.well-known/jwks.json", authRoute.GetJWKS)
 
 	router.Route("/user", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Get("/{id}", userRoute.GetUser)
-			r.Post("/", userRoute.CreateUser)
-			r.Post("/login", authRoute.LoginUser)
+			r.With(rateLimit(ratelimit.PolicyRegister, midle.RateLimitByIP)).Post("/", userRoute.CreateUser)
+			r.With(rateLimit(ratelimit.PolicyAuth, midle.RateLimitByIP)).Post("/login", authRoute.LoginUser)
 		})
 		r.Group(func(r chi.Router) {
 			r.Use(authMiddleware)
+			r.Use(rateLimit(ratelimit.PolicyAuthenticated, midle.RateLimitByToken))
 			r.Put("/{id}", userRoute.UpdateUser)
 			r.Delete("/{id}", userRoute.DeleteUser)
 			r.Post("/logout", authRoute.LogoutUser)
+			r.Get("/trash", postRoute.GetTrash)
+			r.Get("/me/usage", quotaRoute.GetUsage)
+			r.Get("/tokens", apiTokenRoute.ListAPITokens)
+			r.Post("/tokens", apiTokenRoute.CreateAPIToken)
+			r.Delete("/tokens/{tokenID}", apiTokenRoute.RevokeAPIToken)
 		})
 	})
 
 	router.Route("/token", func(r chi.Router) {
-		r.Post("/renew", authRoute.RenewAccessToken)
-		r.With(authMiddleware).Post("/revoke", authRoute.RevokeSessions)
+		r.With(rateLimit(ratelimit.PolicyAuth, midle.RateLimitByIP)).Post("/renew", authRoute.RenewAccessToken)
+		r.With(authMiddleware, rateLimit(ratelimit.PolicyAuthenticated, midle.RateLimitByToken)).Post("/revoke", authRoute.RevokeSessions)
 	})
 
 	router.Route("/post", func(r chi.Router) {
@@ -65,10 +153,56 @@ func SetupRouter(dbConnetcion *sql.DB, config configs.Config) *chi.Mux {
 		})
 		r.Group(func(r chi.Router) {
 			r.Use(authMiddleware)
-			r.Post("/", postRoute.CreatePost)
+			r.Use(rateLimit(ratelimit.PolicyAuthenticated, midle.RateLimitByToken))
+			r.With(rateLimit(ratelimit.PolicyUpload, midle.RateLimitByUser)).Post("/", postRoute.CreatePost)
 			r.Delete("/{id}", postRoute.DeletePost)
+			r.Post("/{id}/restore", postRoute.RestorePost)
+			r.Get("/{id}/metadata", postRoute.GetPostMetadata)
+		})
+	})
+
+	uploadRepository := repository.NewUploadRepository(dbConnetcion, querys, store)
+	uploadRoute := NewUploadRoute(uploadRepository, quotaRepository, postRoute, config.UploadExpiration)
+	router.Route("/upload", func(r chi.Router) {
+		r.Use(uploadRoute.TusResumable)
+		r.Options("/", uploadRoute.Options)
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Use(rateLimit(ratelimit.PolicyAuthenticated, midle.RateLimitByToken))
+			r.With(rateLimit(ratelimit.PolicyUpload, midle.RateLimitByUser)).Post("/", uploadRoute.CreateUpload)
+			r.Head("/{id}", uploadRoute.GetUploadOffset)
+			r.Patch("/{id}", uploadRoute.PatchUpload)
+			r.Delete("/{id}", uploadRoute.DeleteUpload)
+		})
+	})
+
+	router.Route("/admin", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(rateLimit(ratelimit.PolicyAuthenticated, midle.RateLimitByToken))
+		r.Group(func(r chi.Router) {
+			r.Use(midle.RequirePermission(rbac.RoleManage))
+			r.Get("/permissions", roleRoute.ListPermissions)
+			r.Get("/roles", roleRoute.ListRoles)
+			r.Post("/roles", roleRoute.CreateRole)
+			r.Delete("/roles/{role}", roleRoute.DeleteRole)
+			r.Get("/users/{id}/roles", roleRoute.GetUserRoles)
+			r.Post("/users/{id}/roles", roleRoute.AssignUserRole)
+			r.Delete("/users/{id}/roles/{role}", roleRoute.RemoveUserRole)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(midle.RequirePermission(rbac.AuthUnlock))
+			r.Get("/lockouts", lockoutRoute.ListLockouts)
+			r.Delete("/lockouts/{key}", lockoutRoute.Unlock)
+		})
+		r.With(midle.RequirePermission(rbac.AuditRead)).Get("/audit", auditRoute.ListEvents)
+		r.With(midle.RequirePermission(rbac.PostPurge)).Delete("/posts/{id}", postRoute.PurgePost)
+		r.Group(func(r chi.Router) {
+			r.Use(midle.RequirePermission(rbac.QuotaManage))
+			r.Get("/users/{id}/quota", quotaRoute.GetUserQuota)
+			r.Put("/users/{id}/quota", quotaRoute.SetUserQuota)
+			r.Delete("/users/{id}/quota", quotaRoute.DeleteUserQuota)
 		})
 	})
 
-	return router
+	return router, nil
 }
@@ -0,0 +1,367 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/quota"
+	"image-sharing/internal/repository"
+	"image-sharing/pkg/token"
+)
+
+// tus 1.0 resumable uploads, see https://tus.io/protocols/resumable-upload.
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+	// maxUploadMetadata bounds the Upload-Metadata header stored with an upload.
+	maxUploadMetadata = 4096
+)
+
+// PostIDHeader tells the client which post a finished upload became.
+const PostIDHeader = "Post-ID"
+
+type UploadRoute struct {
+	repo       repository.UploadRepository
+	quotas     repository.QuotaRepository
+	posts      *PostRoute
+	expiration time.Duration
+	// locks turns away concurrent PATCH requests of an upload within this
+	// replica early, the row lock taken by AppendUpload covers all replicas.
+	locks sync.Map
+}
+
+func NewUploadRoute(repo repository.UploadRepository, quotas repository.QuotaRepository, posts *PostRoute, expiration time.Duration) *UploadRoute {
+	return &UploadRoute{repo: repo, quotas: quotas, posts: posts, expiration: expiration}
+}
+
+// TusResumable rejects clients speaking another protocol version and marks
+// every response with the version served.
+func (u *UploadRoute) TusResumable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (u *UploadRoute) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(u.posts.maxUploadSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *UploadRoute) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !claims.HasScope(token.ScopePostsWrite) {
+		http.Error(w, "missing scope: "+token.ScopePostsWrite, http.StatusForbidden)
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "deferred upload length is not supported", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length == 0 {
+		u.posts.rejectUpload(w, errEmptyFile)
+		return
+	}
+	if length > u.posts.maxUploadSize {
+		u.posts.rejectUpload(w, &http.MaxBytesError{Limit: u.posts.maxUploadSize})
+		return
+	}
+	// The length is known upfront, so an upload that cannot fit the quota is
+	// refused before any data is sent. Publishing checks again at the end.
+	if err = u.checkQuota(ctx, claims.ID, length); err != nil {
+		if quota.IsExceeded(err) {
+			u.posts.rejectUpload(w, err)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+	metadata := r.Header.Get("Upload-Metadata")
+	if !isValidUploadMetadata(metadata) {
+		http.Error(w, "invalid Upload-Metadata", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := u.repo.CreateUpload(ctx, db.CreateUploadParams{
+		ID:        uuid.NewString(),
+		UserID:    claims.ID,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(u.expiration),
+	})
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/upload/"+upload.ID)
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (u *UploadRoute) GetUploadOffset(w http.ResponseWriter, r *http.Request) {
+	upload, ok := u.loadUpload(w, r)
+	if !ok {
+		return
+	}
+	writeUploadHeaders(w, upload)
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		w.Header().Set("Upload-Metadata", upload.Metadata)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u *UploadRoute) PatchUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Header.Get("Content-Type") != tusContentType {
+		http.Error(w, "Content-Type must be "+tusContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, locked := u.locks.LoadOrStore(id, struct{}{}); locked {
+		http.Error(w, "upload is in use by another request", http.StatusLocked)
+		return
+	}
+	defer u.locks.Delete(id)
+
+	upload, ok := u.loadUpload(w, r)
+	if !ok {
+		return
+	}
+	if offset != upload.UploadOffset {
+		http.Error(w, "Upload-Offset does not match", http.StatusConflict)
+		return
+	}
+	remaining := upload.Length - upload.UploadOffset
+	if r.ContentLength > remaining {
+		http.Error(w, "chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, remaining)
+	expiresAt := time.Now().Add(u.expiration)
+	written, err := u.repo.AppendUpload(ctx, upload, body, expiresAt)
+	upload.UploadOffset += written
+	upload.ExpiresAt = expiresAt
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case err == repository.ErrOffsetMismatch:
+			http.Error(w, "Upload-Offset does not match", http.StatusConflict)
+		case err == repository.ErrUploadLocked:
+			http.Error(w, err.Error(), http.StatusLocked)
+		case errors.As(err, &maxBytesErr):
+			// All bytes up to Upload-Length were stored, so the upload may
+			// be complete and is published like any other.
+			if upload.UploadOffset == upload.Length && !upload.PostID.Valid && !u.finish(w, r, &upload) {
+				return
+			}
+			writeUploadHeaders(w, upload)
+			http.Error(w, "chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		case isRejectedUpload(err):
+			// The client went away, it resumes from the recorded offset.
+			logging.FromContext(ctx).Info("upload interrupted", "upload_id", id, "offset", upload.UploadOffset)
+			writeUploadHeaders(w, upload)
+			http.Error(w, "upload interrupted", http.StatusBadRequest)
+		default:
+			serverError(w, r, err)
+		}
+		return
+	}
+
+	if upload.UploadOffset == upload.Length && !upload.PostID.Valid {
+		if !u.finish(w, r, &upload) {
+			return
+		}
+	}
+	writeUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finish turns a complete upload into a post, validating it exactly like a
+// direct upload. A rejected upload is deleted. The post is linked to the upload
+// when it is created, so an upload published by another request, possibly on
+// another replica, is reported with that post instead of being published again.
+func (u *UploadRoute) finish(w http.ResponseWriter, r *http.Request, upload *db.Upload) bool {
+	ctx := r.Context()
+	media, err := u.repo.OpenUpload(ctx, upload.ID)
+	if err != nil {
+		// The data is gone once another request published the upload.
+		return u.published(w, r, upload, err)
+	}
+	defer media.Close()
+
+	keepMetadata, _ := strconv.ParseBool(uploadMetadataValue(upload.Metadata, "keep_metadata"))
+	post, err := u.posts.publish(ctx, upload.UserID, media, keepMetadata, upload.ID)
+	if err != nil {
+		if err == repository.ErrUploadCompleted || err == repository.ErrNotFound {
+			return u.published(w, r, upload, err)
+		}
+		if isRejectedUpload(err) {
+			if err := u.repo.DeleteUpload(ctx, upload.ID); err != nil {
+				logging.FromContext(ctx).Error("failed to delete rejected upload", "upload_id", upload.ID, "error", err)
+			}
+			u.posts.rejectUpload(w, err)
+			return false
+		}
+		serverError(w, r, err)
+		return false
+	}
+	upload.PostID.Int32, upload.PostID.Valid = post.ID, true
+	if err = u.repo.CompleteUpload(ctx, upload.ID); err != nil {
+		// The post is made, the data goes once the upload expires.
+		logging.FromContext(ctx).Error("failed to remove completed upload data", "upload_id", upload.ID, "error", err)
+	}
+	return true
+}
+
+// published picks up the post another request made of the upload. Without
+// one err is reported.
+func (u *UploadRoute) published(w http.ResponseWriter, r *http.Request, upload *db.Upload, err error) bool {
+	current, getErr := u.repo.GetUpload(r.Context(), upload.ID)
+	switch {
+	case getErr == nil && current.PostID.Valid:
+		upload.PostID = current.PostID
+		return true
+	case getErr == repository.ErrNotFound:
+		http.Error(w, "upload not found", http.StatusNotFound)
+	default:
+		serverError(w, r, err)
+	}
+	return false
+}
+
+func (u *UploadRoute) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	upload, ok := u.loadUpload(w, r)
+	if !ok {
+		return
+	}
+	if err := u.repo.DeleteUpload(r.Context(), upload.ID); err != nil && err != repository.ErrNotFound {
+		serverError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadUpload returns the upload of the URL if it belongs to the caller and has
+// not expired. Every upload request needs the scope to create posts.
+func (u *UploadRoute) loadUpload(w http.ResponseWriter, r *http.Request) (db.Upload, bool) {
+	claims, err := CheckClaims(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return db.Upload{}, false
+	}
+	if !claims.HasScope(token.ScopePostsWrite) {
+		http.Error(w, "missing scope: "+token.ScopePostsWrite, http.StatusForbidden)
+		return db.Upload{}, false
+	}
+	upload, err := u.repo.GetUpload(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "upload not found", http.StatusNotFound)
+		} else {
+			serverError(w, r, err)
+		}
+		return db.Upload{}, false
+	}
+	// Uploads of other users are reported as missing so their ids are not confirmed.
+	if upload.UserID != claims.ID {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return db.Upload{}, false
+	}
+	if !upload.ExpiresAt.After(time.Now()) {
+		http.Error(w, "upload expired", http.StatusGone)
+		return db.Upload{}, false
+	}
+	return upload, true
+}
+
+func writeUploadHeaders(w http.ResponseWriter, upload db.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.PostID.Valid {
+		w.Header().Set(PostIDHeader, strconv.Itoa(int(upload.PostID.Int32)))
+	}
+}
+
+// isValidUploadMetadata checks the "key base64value,key" format of Upload-Metadata.
+func isValidUploadMetadata(metadata string) bool {
+	if metadata == "" {
+		return true
+	}
+	if len(metadata) > maxUploadMetadata {
+		return false
+	}
+	for _, pair := range strings.Split(metadata, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" || strings.ContainsAny(key, " ,") {
+			return false
+		}
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// uploadMetadataValue decodes the value of key from Upload-Metadata.
+func uploadMetadataValue(metadata string, key string) string {
+	for _, pair := range strings.Split(metadata, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if name == key {
+			decoded, _ := base64.StdEncoding.DecodeString(value)
+			return string(decoded)
+		}
+	}
+	return ""
+}
+
+func (u *UploadRoute) checkQuota(ctx context.Context, userID int32, length int64) error {
+	limits, err := u.quotas.GetLimits(ctx, userID)
+	if err != nil {
+		return err
+	}
+	usage, err := u.quotas.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return limits.Check(usage, length)
+}
@@ -5,16 +5,19 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
+	"image-sharing/internal/audit"
+	"image-sharing/internal/bruteforce"
 	"image-sharing/internal/db/gen"
 	"image-sharing/internal/middleware"
+	"image-sharing/internal/rbac"
 	"image-sharing/internal/repository"
 	"image-sharing/pkg/password"
+	"image-sharing/pkg/token"
 )
 
 type UserRequest struct {
@@ -29,17 +32,20 @@ type UserResponse struct {
 }
 
 type UserRoute struct {
-	repo repository.UserRepository
+	repo    repository.UserRepository
+	tracker *bruteforce.Tracker
+	hasher  *password.Hasher
+	policy  *password.Policy
+	audit   *audit.Service
 }
 
-func NewUserRoute(repo repository.UserRepository) *UserRoute {
-	return &UserRoute{repo: repo}
+func NewUserRoute(repo repository.UserRepository, tracker *bruteforce.Tracker, hasher *password.Hasher, policy *password.Policy, auditService *audit.Service) *UserRoute {
+	return &UserRoute{repo: repo, tracker: tracker, hasher: hasher, policy: policy, audit: auditService}
 }
 
 func (u *UserRoute) GetUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
-	fmt.Println(chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
@@ -50,7 +56,7 @@ func (u *UserRoute) GetUser(w http.ResponseWriter, r *http.Request) {
 		if err == repository.ErrNotFound {
 			http.Error(w, "user not found", http.StatusNotFound)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			serverError(w, r, err)
 		}
 		return
 	}
@@ -60,6 +66,14 @@ func (u *UserRoute) GetUser(w http.ResponseWriter, r *http.Request) {
 
 func (u *UserRoute) CreateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	// Every registration counts against the client address, successful or not.
+	ip := middleware.ClientIP(r)
+	if err := u.tracker.Check(ctx, bruteforce.KindRegister, ip); err != nil {
+		writeAttemptError(w, r, err)
+		return
+	}
+	recordAttempt(r, u.tracker, u.audit, bruteforce.KindRegister, ip)
+
 	var user LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
@@ -71,9 +85,14 @@ func (u *UserRoute) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash, err := password.HashPassword(user.Password)
+	if err = u.policy.Validate(user.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := u.hasher.Hash(user.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	user.Password = hash
@@ -82,7 +101,11 @@ func (u *UserRoute) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Login:        user.Login,
 		PasswordHash: hash})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if err == repository.ErrLoginTaken {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			serverError(w, r, err)
+		}
 		return
 	}
 
@@ -98,7 +121,13 @@ func (u *UserRoute) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = CheckOwnership(ctx, id)
+	err = CheckScope(ctx, token.ScopeUsersWrite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = CheckOwnership(ctx, id, rbac.UserUpdateAny)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
@@ -117,7 +146,7 @@ func (u *UserRoute) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Description: sql.NullString{String: user.Description, Valid: user.Description != ""},
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -132,7 +161,13 @@ func (u *UserRoute) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = CheckOwnership(ctx, id)
+	err = CheckScope(ctx, token.ScopeUsersWrite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = CheckOwnership(ctx, id, rbac.UserDeleteAny)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
@@ -144,9 +179,15 @@ func (u *UserRoute) DeleteUser(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "user not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
+	u.audit.Record(r, audit.Event{
+		Action:     audit.ActionUserDelete,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.Itoa(id),
+		Outcome:    audit.OutcomeSuccess,
+	})
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("user deleted"))
 }
@@ -159,12 +200,24 @@ func CheckClaims(ctx context.Context) (*middleware.AccessClaims, error) {
 	return claims, nil
 }
 
-func CheckOwnership(ctx context.Context, id int) error {
+func CheckScope(ctx context.Context, scope string) error {
+	claims, err := CheckClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if !claims.HasScope(scope) {
+		return errors.New("missing scope: " + scope)
+	}
+	return nil
+}
+
+// CheckOwnership passes for the user itself or anyone granted the permission.
+func CheckOwnership(ctx context.Context, id int, permission string) error {
 	claims, ok := ctx.Value(middleware.AuthKey{}).(*middleware.AccessClaims)
 	if !ok {
 		return errors.New("invalid claims")
 	}
-	if !claims.IsAdmin && claims.ID != int32(id) {
+	if claims.ID != int32(id) && !claims.HasPermission(permission) {
 		return errors.New("not an owner")
 	}
 	return nil
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Filesystem stores blobs as files in a single directory.
+type Filesystem struct {
+	dir string
+}
+
+func NewFilesystem(dir string) *Filesystem {
+	return &Filesystem{dir: dir}
+}
+
+// Path returns where the blob called name is stored.
+func (s *Filesystem) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// ContentName names a blob after the SHA-256 of its content. Blobs are spread
+// over subdirectories by the first byte of the hash to keep directories small.
+func ContentName(sum, ext string) string {
+	return filepath.Join(sum[:2], sum+ext)
+}
+
+// Create starts a new blob. Data is written into a temporary file first so an
+// interrupted upload never leaves a partial file under its final name.
+func (s *Filesystem) Create() (*Blob, error) {
+	file, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Blob{file: file, hash: sha256.New()}, nil
+}
+
+// Remove deletes the blob at path, a missing blob is not an error.
+func (s *Filesystem) Remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Blob is a blob being written. It computes the size and SHA-256 of the
+// content on the fly and has to be finished with Commit or Abort.
+type Blob struct {
+	file *os.File
+	hash hash.Hash
+	size int64
+	done bool
+}
+
+func (b *Blob) Write(p []byte) (int, error) {
+	n, err := b.file.Write(p)
+	b.hash.Write(p[:n])
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *Blob) Size() int64 {
+	return b.size
+}
+
+// SHA256 returns the hex encoded hash of everything written so far.
+func (b *Blob) SHA256() string {
+	return hex.EncodeToString(b.hash.Sum(nil))
+}
+
+// Commit makes the blob visible at path. An existing file is replaced, which
+// is harmless for content addressed paths.
+func (b *Blob) Commit(path string) error {
+	b.done = true
+	if err := b.file.Close(); err != nil {
+		os.Remove(b.file.Name())
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		os.Remove(b.file.Name())
+		return err
+	}
+	if err := os.Rename(b.file.Name(), path); err != nil {
+		os.Remove(b.file.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the blob unless it was committed, so it can be deferred.
+func (b *Blob) Abort() {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.file.Close()
+	os.Remove(b.file.Name())
+}
+
+// partialPath is where the data of an unfinished resumable upload is kept.
+func (s *Filesystem) partialPath(id string) string {
+	return filepath.Join(s.dir, ".partial-"+id)
+}
+
+// CreatePartial starts an empty resumable upload.
+func (s *Filesystem) CreatePartial(id string) error {
+	file, err := os.OpenFile(s.partialPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// AppendPartial writes r at offset of the upload and returns how many bytes
+// were stored. Anything after offset is dropped first: it was written by a
+// request whose offset was never recorded. Bytes received before r fails are
+// kept so the client can resume from there.
+func (s *Filesystem) AppendPartial(id string, offset int64, r io.Reader) (int64, error) {
+	file, err := os.OpenFile(s.partialPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return 0, err
+	}
+	written, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
+
+func (s *Filesystem) OpenPartial(id string) (*os.File, error) {
+	return os.Open(s.partialPath(id))
+}
+
+func (s *Filesystem) RemovePartial(id string) error {
+	err := os.Remove(s.partialPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Orphan is a stored file that nothing in the database refers to.
+type Orphan struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Orphans walks the store and returns the files that are neither one of the
+// known paths nor the partial data of one of the uploads. Files modified
+// after olderThan are skipped, so media of uploads still being written is
+// never reported. Paths are compared relative to the store, the directory may
+// be spelled differently than when the files were stored. When none of the
+// known paths lies inside the store nothing is returned, since every file
+// would look like an orphan.
+func (s *Filesystem) Orphans(known []string, uploads []string, olderThan time.Time) ([]Orphan, error) {
+	root, err := filepath.Abs(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	relative := func(path string) (string, bool) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", false
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		return rel, true
+	}
+
+	referenced := make(map[string]struct{}, len(known)+len(uploads))
+	for _, path := range known {
+		if rel, ok := relative(path); ok {
+			referenced[rel] = struct{}{}
+		}
+	}
+	if len(known) > 0 && len(referenced) == 0 {
+		return nil, fmt.Errorf("none of the %d stored paths is inside %s, it may differ from the directory the files were stored in", len(known), root)
+	}
+	for _, id := range uploads {
+		if rel, ok := relative(s.partialPath(id)); ok {
+			referenced[rel] = struct{}{}
+		}
+	}
+
+	var orphans []Orphan
+	err = filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if rel, ok := relative(path); !ok {
+			return nil
+		} else if _, ok := referenced[rel]; ok {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(olderThan) {
+			return nil
+		}
+		orphans = append(orphans, Orphan{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return orphans, err
+}
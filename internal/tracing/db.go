@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	db "image-sharing/internal/db/gen"
+)
+
+// queryNamePattern extracts the sqlc query name from the leading comment.
+var queryNamePattern = regexp.MustCompile(`^-- name: (\w+)`)
+
+// dbtx starts a client span for every query sent through it.
+type dbtx struct {
+	next db.DBTX
+}
+
+// WrapDB instruments a connection, pool or transaction used by sqlc queries.
+func WrapDB(next db.DBTX) db.DBTX {
+	return &dbtx{next: next}
+}
+
+func (d *dbtx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	result, err := d.next.ExecContext(ctx, query, args...)
+	End(span, err)
+	return result, err
+}
+
+func (d *dbtx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	stmt, err := d.next.PrepareContext(ctx, query)
+	End(span, err)
+	return stmt, err
+}
+
+func (d *dbtx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := d.next.QueryContext(ctx, query, args...)
+	End(span, err)
+	return rows, err
+}
+
+func (d *dbtx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := d.next.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	End(span, err)
+	return row
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := "db.query"
+	attributes := []attribute.KeyValue{semconv.DBSystemNamePostgreSQL, semconv.DBQueryText(query)}
+	if match := queryNamePattern.FindStringSubmatch(query); match != nil {
+		name = match[1]
+		attributes = append(attributes, semconv.DBOperationName(match[1]))
+	}
+	return otel.Tracer(serviceName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupTest installs a provider exporting into memory and returns a function
+// flushing and returning the ended spans.
+func setupTest(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(exporter, 1)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return func() tracetest.SpanStubs {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+func TestMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	spans := setupTest(t)
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/post/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	request := httptest.NewRequest(http.MethodGet, "/post/42", nil)
+	request.Header.Set("traceparent", parent)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	got := spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1", len(got))
+	}
+	span := got[0]
+	if span.Name != "GET /post/{id}" {
+		t.Errorf("span name = %q, want %q", span.Name, "GET /post/{id}")
+	}
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind = %v, want server", span.SpanKind)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, the caller's trace was not continued", span.SpanContext.TraceID())
+	}
+	if !hasAttribute(span.Attributes, attribute.String("http.route", "/post/{id}")) {
+		t.Errorf("missing http.route attribute in %v", span.Attributes)
+	}
+}
+
+type fakeDB struct {
+	err error
+}
+
+func (f *fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestWrapDBStartsSpanPerQuery(t *testing.T) {
+	spans := setupTest(t)
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name     string
+		query    string
+		err      error
+		wantName string
+		wantCode codes.Code
+	}{
+		{name: "named query", query: "-- name: DeletePost :exec\nDELETE FROM posts WHERE id = $1", wantName: "DeletePost", wantCode: codes.Unset},
+		{name: "plain query", query: "SELECT 1", wantName: "db.query", wantCode: codes.Unset},
+		{name: "failed query", query: "-- name: GetPost :one\nSELECT 1", err: failure, wantName: "GetPost", wantCode: codes.Error},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+			_, err := WrapDB(&fakeDB{err: test.err}).ExecContext(ctx, test.query)
+			parent.End()
+			if err != test.err {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+
+			got := spans()
+			var span *tracetest.SpanStub
+			for i := range got {
+				if got[i].Name == test.wantName && got[i].Parent.SpanID() == parent.SpanContext().SpanID() {
+					span = &got[i]
+				}
+			}
+			if span == nil {
+				t.Fatalf("no %q span under the parent in %d spans", test.wantName, len(got))
+			}
+			if span.SpanKind != trace.SpanKindClient {
+				t.Errorf("span kind = %v, want client", span.SpanKind)
+			}
+			if span.Status.Code != test.wantCode {
+				t.Errorf("status = %v, want %v", span.Status.Code, test.wantCode)
+			}
+			if !hasAttribute(span.Attributes, attribute.String("db.system.name", "postgresql")) {
+				t.Errorf("missing db.system.name attribute in %v", span.Attributes)
+			}
+		})
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attribute := range attributes {
+		if attribute == want {
+			return true
+		}
+	}
+	return false
+}
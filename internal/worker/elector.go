@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	db "image-sharing/internal/db/gen"
+)
+
+// LeaderLockKey is the advisory lock that elects the replica running background jobs.
+const LeaderLockKey int64 = 0x696d6773
+
+// Elector holds a PostgreSQL advisory lock on a dedicated connection. The lock
+// is released by the server as soon as that connection goes away, so another
+// replica can take over when the leader dies.
+type Elector struct {
+	dbConnection *sql.DB
+	key          int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewElector(dbConnection *sql.DB, key int64) *Elector {
+	return &Elector{dbConnection: dbConnection, key: key}
+}
+
+// IsLeader tries to take the lock if it is not held yet and reports whether
+// this process currently owns it.
+func (e *Elector) IsLeader(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		if err := e.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		e.conn.Close()
+		e.conn = nil
+	}
+
+	conn, err := e.dbConnection.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	acquired, err := db.New(conn).TryAdvisoryLock(ctx, e.key)
+	if err != nil || !acquired {
+		conn.Close()
+		return false, err
+	}
+	e.conn = conn
+	return true, nil
+}
+
+// Release gives up leadership.
+func (e *Elector) Release(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+	_, err := db.New(e.conn).AdvisoryUnlock(ctx, e.key)
+	e.conn.Close()
+	e.conn = nil
+	return err
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/tracing"
+)
+
+const rateLimitCleanupBatch = 1000
+
+// RateLimitCleaner deletes buckets of the postgres rate limit store that have
+// refilled completely, they behave exactly like missing rows.
+type RateLimitCleaner struct {
+	queries *db.Queries
+}
+
+func NewRateLimitCleaner(dbConnection *sql.DB) *RateLimitCleaner {
+	return &RateLimitCleaner{queries: db.New(tracing.WrapDB(dbConnection))}
+}
+
+func (c *RateLimitCleaner) Name() string {
+	return "ratelimit_cleanup"
+}
+
+func (c *RateLimitCleaner) Run(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		purged, err := c.queries.PurgeRateLimits(ctx, db.PurgeRateLimitsParams{
+			Now:       time.Now(),
+			BatchSize: rateLimitCleanupBatch,
+		})
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < rateLimitCleanupBatch {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"image-sharing/internal/metrics"
+)
+
+// Job is a periodic task. Run returns the number of processed items.
+type Job interface {
+	Name() string
+	Run(ctx context.Context) (int64, error)
+}
+
+// Backlogger is implemented by jobs that can report the work waiting for
+// them. The scheduler exports it as queue depth and latency.
+type Backlogger interface {
+	Backlog(ctx context.Context) (depth int64, oldest time.Duration, err error)
+}
+
+type scheduledJob struct {
+	job      Job
+	interval time.Duration
+}
+
+// Scheduler runs jobs at fixed intervals, but only on the replica that holds
+// the leader lock.
+type Scheduler struct {
+	elector *Elector
+	metrics *metrics.JobMetrics
+	jobs    []scheduledJob
+}
+
+func NewScheduler(elector *Elector, jobMetrics *metrics.JobMetrics) *Scheduler {
+	return &Scheduler{elector: elector, metrics: jobMetrics}
+}
+
+func (s *Scheduler) Add(job Job, interval time.Duration) {
+	s.jobs = append(s.jobs, scheduledJob{job: job, interval: interval})
+}
+
+// Run blocks until ctx is cancelled and releases leadership before returning.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, scheduled := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, scheduled)
+		}()
+	}
+	wg.Wait()
+
+	if err := s.elector.Release(context.WithoutCancel(ctx)); err != nil {
+		slog.Error("failed to release leader lock", "error", err)
+	}
+	s.metrics.SetLeader(false)
+}
+
+func (s *Scheduler) loop(ctx context.Context, scheduled scheduledJob) {
+	ticker := time.NewTicker(scheduled.interval)
+	defer ticker.Stop()
+	for {
+		s.runOnce(ctx, scheduled.job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	leader, err := s.elector.IsLeader(ctx)
+	if err != nil {
+		slog.Error("leader election failed", "error", err)
+	}
+	s.metrics.SetLeader(leader)
+	if !leader {
+		return
+	}
+
+	start := time.Now()
+	processed, err := job.Run(ctx)
+	s.metrics.Observe(job.Name(), time.Since(start), processed, err)
+	if err != nil {
+		slog.Error("job failed", "job", job.Name(), "error", err)
+		return
+	}
+	slog.Debug("job finished", "job", job.Name(), "processed", processed)
+
+	if backlogger, ok := job.(Backlogger); ok {
+		depth, oldest, err := backlogger.Backlog(ctx)
+		if err != nil {
+			slog.Error("job backlog failed", "job", job.Name(), "error", err)
+			return
+		}
+		s.metrics.SetBacklog(job.Name(), depth, oldest)
+	}
+}
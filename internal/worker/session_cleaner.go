@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/tracing"
+)
+
+// SessionCleaner deletes expired sessions and revoked sessions older than the
+// retention window. Rows are removed in small batches so a single run never
+// holds locks on a large part of the table.
+type SessionCleaner struct {
+	queries   *db.Queries
+	retention time.Duration
+	batchSize int32
+}
+
+func NewSessionCleaner(dbConnection *sql.DB, retention time.Duration, batchSize int32) *SessionCleaner {
+	return &SessionCleaner{
+		queries:   db.New(tracing.WrapDB(dbConnection)),
+		retention: retention,
+		batchSize: batchSize,
+	}
+}
+
+func (c *SessionCleaner) Name() string {
+	return "session_cleanup"
+}
+
+func (c *SessionCleaner) Run(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		purged, err := c.queries.PurgeSessions(ctx, db.PurgeSessionsParams{
+			Now:                     time.Now(),
+			RevokedRetentionSeconds: c.retention.Seconds(),
+			BatchSize:               c.batchSize,
+		})
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < int64(c.batchSize) {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// Backlog reports the expired sessions still waiting to be purged.
+func (c *SessionCleaner) Backlog(ctx context.Context) (int64, time.Duration, error) {
+	backlog, err := c.queries.GetSessionBacklog(ctx, time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+	return backlog.Depth, time.Duration(backlog.OldestSeconds * float64(time.Second)), nil
+}
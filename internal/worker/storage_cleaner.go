@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/logging"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
+)
+
+const (
+	storageRetryDelay    = time.Minute
+	storageMaxRetryDelay = 6 * time.Hour
+)
+
+// StorageCleaner removes the files queued in storage_deletions. Each entry is
+// claimed in its own transaction and a blob file is only removed while its
+// row is locked and still unreferenced, so a concurrent upload of the same
+// content either waits or keeps the file.
+type StorageCleaner struct {
+	db      *sql.DB
+	queries *db.Queries
+	storage *storage.Filesystem
+}
+
+func NewStorageCleaner(dbConnection *sql.DB, store *storage.Filesystem) *StorageCleaner {
+	return &StorageCleaner{
+		db:      dbConnection,
+		queries: db.New(tracing.WrapDB(dbConnection)),
+		storage: store,
+	}
+}
+
+func (c *StorageCleaner) Name() string {
+	return "storage_cleanup"
+}
+
+func (c *StorageCleaner) Run(ctx context.Context) (int64, error) {
+	var total int64
+	now := time.Now()
+	for {
+		removed, err := c.next(ctx, now)
+		if err == sql.ErrNoRows {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		if removed {
+			total++
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// next processes a single queued deletion and reports whether it was done. A
+// failed file removal is recorded on the entry with an exponential backoff and
+// does not stop the run.
+func (c *StorageCleaner) next(ctx context.Context, now time.Time) (bool, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	queries := db.New(tracing.WrapDB(tx))
+
+	deletion, err := queries.ClaimStorageDeletion(ctx, now)
+	if err != nil {
+		return false, err
+	}
+	if err := c.remove(ctx, queries, deletion); err != nil {
+		tx.Rollback()
+		logging.FromContext(ctx).Warn("storage deletion failed",
+			"path", deletion.Path, "attempts", deletion.Attempts+1, "error", err)
+		delay := storageRetryDelay << min(deletion.Attempts, 16)
+		if err := c.queries.FailStorageDeletion(ctx, db.FailStorageDeletionParams{
+			ID:            deletion.ID,
+			LastError:     err.Error(),
+			NextAttemptAt: time.Now().Add(min(delay, storageMaxRetryDelay)),
+		}); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	if err := queries.DeleteStorageDeletion(ctx, deletion.ID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (c *StorageCleaner) remove(ctx context.Context, queries *db.Queries, deletion db.StorageDeletion) error {
+	if deletion.Sha256 == "" {
+		return c.storage.Remove(deletion.Path)
+	}
+	path, err := queries.ReleaseBlob(ctx, deletion.Sha256)
+	if err == sql.ErrNoRows {
+		// Referenced again or already released by an earlier entry.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.storage.Remove(path)
+}
+
+// Backlog reports the files still waiting to be removed.
+func (c *StorageCleaner) Backlog(ctx context.Context) (int64, time.Duration, error) {
+	backlog, err := c.queries.GetStorageDeletionBacklog(ctx, time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+	return backlog.Depth, time.Duration(backlog.OldestSeconds * float64(time.Second)), nil
+}
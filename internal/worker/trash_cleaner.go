@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/tracing"
+)
+
+const trashPurgeBatch = 100
+
+// TrashCleaner permanently deletes posts that stayed in the trash longer than
+// the retention period. Their files are queued for removal by the database.
+type TrashCleaner struct {
+	queries   *db.Queries
+	retention time.Duration
+}
+
+func NewTrashCleaner(dbConnection *sql.DB, retention time.Duration) *TrashCleaner {
+	return &TrashCleaner{queries: db.New(tracing.WrapDB(dbConnection)), retention: retention}
+}
+
+func (c *TrashCleaner) Name() string {
+	return "trash_purge"
+}
+
+func (c *TrashCleaner) Run(ctx context.Context) (int64, error) {
+	var total int64
+	deletedBefore := time.Now().Add(-c.retention)
+	for {
+		purged, err := c.queries.PurgeTrashedPosts(ctx, db.PurgeTrashedPostsParams{
+			DeletedBefore: deletedBefore,
+			BatchSize:     trashPurgeBatch,
+		})
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < trashPurgeBatch {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "image-sharing/internal/db/gen"
+	"image-sharing/internal/storage"
+	"image-sharing/internal/tracing"
+)
+
+const uploadCleanupBatch = 100
+
+// UploadCleaner removes resumable uploads that expired, together with the
+// partial data of those that were never finished.
+type UploadCleaner struct {
+	queries *db.Queries
+	storage *storage.Filesystem
+}
+
+func NewUploadCleaner(dbConnection *sql.DB, store *storage.Filesystem) *UploadCleaner {
+	return &UploadCleaner{queries: db.New(tracing.WrapDB(dbConnection)), storage: store}
+}
+
+func (c *UploadCleaner) Name() string {
+	return "upload_cleanup"
+}
+
+func (c *UploadCleaner) Run(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		uploads, err := c.queries.ListExpiredUploads(ctx, db.ListExpiredUploadsParams{
+			ExpiresAt: time.Now(),
+			Limit:     uploadCleanupBatch,
+		})
+		if err != nil {
+			return total, err
+		}
+		for _, upload := range uploads {
+			if err := c.storage.RemovePartial(upload.ID); err != nil {
+				return total, err
+			}
+			if _, err := c.queries.DeleteUpload(ctx, upload.ID); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(uploads) < uploadCleanupBatch {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -1,17 +1,190 @@
 package password
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// bcrypt silently ignores everything past 72 bytes.
+const bcryptMaxLength = 72
+
+var (
+	ErrMismatch        = errors.New("password does not match")
+	ErrUnknownFormat   = errors.New("unknown password hash format")
+	ErrBcryptTooLong   = errors.New("password is too long for bcrypt")
+	ErrUnsupportedAlgo = errors.New("unsupported password hash algorithm")
+)
+
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher produces hashes with the preferred algorithm and verifies hashes of any supported one.
+// Argon2id hashes use the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+type Hasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+func NewHasher(algorithm string, argon2Params Argon2Params, bcryptCost int) (*Hasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		if argon2Params.Memory == 0 || argon2Params.Iterations == 0 || argon2Params.Parallelism == 0 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		if argon2Params.SaltLength == 0 {
+			argon2Params.SaltLength = DefaultArgon2Params.SaltLength
+		}
+		if argon2Params.KeyLength == 0 {
+			argon2Params.KeyLength = DefaultArgon2Params.KeyLength
+		}
+	case AlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, ErrUnsupportedAlgo
+	}
+	return &Hasher{Algorithm: algorithm, Argon2: argon2Params, BcryptCost: bcryptCost}, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		return hashArgon2id(password, h.Argon2)
+	case AlgorithmBcrypt:
+		if len(password) > bcryptMaxLength {
+			return "", ErrBcryptTooLong
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	}
+	return "", ErrUnsupportedAlgo
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or other parameters than preferred.
+func (h *Hasher) NeedsRehash(hashedPassword string) bool {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		params, _, _, err := decodeArgon2id(hashedPassword)
+		if err != nil {
+			return true
+		}
+		return params.Memory != h.Argon2.Memory ||
+			params.Iterations != h.Argon2.Iterations ||
+			params.Parallelism != h.Argon2.Parallelism ||
+			params.KeyLength != h.Argon2.KeyLength
+	case AlgorithmBcrypt:
+		if !isBcrypt(hashedPassword) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != h.BcryptCost
 	}
-	return string(hashed), nil
+	return false
 }
 
+// CheckPasswrod verifies a password against an argon2id or bcrypt hash.
 func CheckPasswrod(password string, hashedPasswrod string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPasswrod), []byte(password))
+	switch {
+	case strings.HasPrefix(hashedPasswrod, "$"+AlgorithmArgon2id+"$"):
+		params, salt, key, err := decodeArgon2id(hashedPasswrod)
+		if err != nil {
+			return err
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	case isBcrypt(hashedPasswrod):
+		if len(password) > bcryptMaxLength {
+			return ErrMismatch
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(hashedPasswrod), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrMismatch
+		}
+		return err
+	}
+	return ErrUnknownFormat
+}
+
+func hashArgon2id(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hashedPassword string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+func isBcrypt(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
 }
@@ -0,0 +1,59 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength keeps hashing cost bounded for absurdly long inputs.
+const MaxLength = 1024
+
+var ErrBreached = errors.New("password is too common or appeared in a data breach")
+
+type Policy struct {
+	MinLength int
+	breached  map[string]struct{}
+}
+
+// NewPolicy loads the optional breach list, a text file with one known-bad password per line.
+func NewPolicy(minLength int, breachListPath string) (*Policy, error) {
+	policy := &Policy{MinLength: minLength, breached: make(map[string]struct{})}
+	if breachListPath == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(breachListPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			policy.breached[line] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *Policy) Validate(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > MaxLength {
+		return fmt.Errorf("password must be at most %d bytes", MaxLength)
+	}
+	if _, ok := p.breached[password]; ok {
+		return ErrBreached
+	}
+	return nil
+}
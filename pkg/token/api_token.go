@@ -0,0 +1,62 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APITokenPrefix marks personal access tokens so they can be told apart from JWTs.
+const APITokenPrefix = "isk_"
+
+const (
+	apiTokenIDSize     = 6
+	apiTokenSecretSize = 32
+)
+
+// NewAPIToken generates a personal access token of the form isk_<prefix>_<secret>.
+// Only the prefix and the hash should be stored, the token itself is shown once.
+func NewAPIToken() (apiToken string, prefix string, hash string, err error) {
+	id := make([]byte, apiTokenIDSize)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	secret := make([]byte, apiTokenSecretSize)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(id)
+	apiToken = APITokenPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return apiToken, prefix, HashAPIToken(apiToken), nil
+}
+
+// IsAPIToken reports whether the token looks like a personal access token.
+func IsAPIToken(apiToken string) bool {
+	return strings.HasPrefix(apiToken, APITokenPrefix)
+}
+
+// ParseAPIToken returns the lookup prefix of a personal access token.
+func ParseAPIToken(apiToken string) (string, bool) {
+	rest, ok := strings.CutPrefix(apiToken, APITokenPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != hex.EncodedLen(apiTokenIDSize) || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+func HashAPIToken(apiToken string) string {
+	sum := sha256.Sum256([]byte(apiToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func CheckAPIToken(apiToken string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIToken(apiToken)), []byte(hash)) == 1
+}
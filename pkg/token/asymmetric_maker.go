@@ -0,0 +1,113 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type AsymmetricMaker struct {
+	method           jwt.SigningMethod
+	signingKey       Key
+	verificationKeys map[string]Key
+	keyOrder         []string
+}
+
+func NewAsymmetricMaker(algorithm string, signingKeyID string, keys []Key) (*AsymmetricMaker, error) {
+	m := &AsymmetricMaker{verificationKeys: make(map[string]Key, len(keys))}
+	switch algorithm {
+	case AlgorithmRS256:
+		m.method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		m.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported asymmetric algorithm %q", algorithm)
+	}
+
+	for _, key := range keys {
+		if _, ok := m.verificationKeys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		m.verificationKeys[key.ID] = key
+		m.keyOrder = append(m.keyOrder, key.ID)
+	}
+
+	signingKey, ok := m.verificationKeys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingKeyID)
+	}
+	if signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+	if keyAlgorithm(signingKey.PublicKey) != algorithm {
+		return nil, fmt.Errorf("signing key %q can't be used with %s", signingKeyID, algorithm)
+	}
+	m.signingKey = signingKey
+	return m, nil
+}
+
+func (m *AsymmetricMaker) CreateToken(id int32, login string, duration time.Duration) (string, *UserClaims, error) {
+	claims, err := NewUserClaims(id, login, duration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = m.signingKey.ID
+	tokenString, err := token.SignedString(m.signingKey.PrivateKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, claims, nil
+}
+
+func (m *AsymmetricMaker) VerifyToken(tokenString string) (*UserClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("missing key id")
+		}
+		key, ok := m.verificationKeys[kid]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if t.Method.Alg() != keyAlgorithm(key.PublicKey) {
+			return nil, errors.New("invalid token signing method")
+		}
+		return key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}))
+	if err != nil {
+		return nil, errors.New("error parsing token")
+	}
+
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+func (m *AsymmetricMaker) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keyOrder))}
+	for _, kid := range m.keyOrder {
+		if jwk, ok := publicKeyToJWK(kid, m.verificationKeys[kid].PublicKey); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func keyAlgorithm(key any) string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRS256
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA
+	}
+	return ""
+}
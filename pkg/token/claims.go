@@ -8,22 +8,20 @@ import (
 )
 
 type UserClaims struct {
-	ID      int32  `json:"id"`
-	Login   string `json:"login"`
-	IsAdmin bool   `json:"is_admin"`
+	ID    int32  `json:"id"`
+	Login string `json:"login"`
 	jwt.RegisteredClaims
 }
 
-func NewUserClaims(id int32, login string, isAdmin bool, duration time.Duration) (*UserClaims, error) {
+func NewUserClaims(id int32, login string, duration time.Duration) (*UserClaims, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
 
 	userClaims := &UserClaims{
-		ID:      id,
-		Login:   login,
-		IsAdmin: isAdmin,
+		ID:    id,
+		Login: login,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        tokenID.String(),
 			Subject:   login,
@@ -15,8 +15,8 @@ func NewJWTMaker(secretKey string) *JWTMaker {
 	return &JWTMaker{secretKey: secretKey}
 }
 
-func (m *JWTMaker) CreateToken(id int32, login string, isAdmin bool, duration time.Duration) (string, *UserClaims, error) {
-	claims, err := NewUserClaims(id, login, isAdmin, duration)
+func (m *JWTMaker) CreateToken(id int32, login string, duration time.Duration) (string, *UserClaims, error) {
+	claims, err := NewUserClaims(id, login, duration)
 	if err != nil {
 		return "", nil, err
 	}
@@ -0,0 +1,97 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Key is a verification key, optionally with its private half for signing.
+// Retired keys are kept as public keys only so tokens they signed stay valid until expiry.
+type Key struct {
+	ID         string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// LoadKeys reads every *.pem file in dir. The file name without extension is used as the key id.
+func LoadKeys(dir string) ([]Key, error) {
+	if dir == "" {
+		return nil, errors.New("jwt keys directory not specified")
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", dir)
+	}
+	return keys, nil
+}
+
+func ParseKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("invalid pem data")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok || !isSupportedKey(signer.Public()) {
+			return Key{}, errors.New("unsupported private key type")
+		}
+		return Key{ID: id, PrivateKey: signer, PublicKey: signer.Public()}, nil
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{ID: id, PrivateKey: parsed, PublicKey: parsed.Public()}, nil
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		if !isSupportedKey(parsed) {
+			return Key{}, errors.New("unsupported public key type")
+		}
+		return Key{ID: id, PublicKey: parsed}, nil
+	default:
+		return Key{}, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+}
+
+func isSupportedKey(key crypto.PublicKey) bool {
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+type Maker interface {
+	CreateToken(id int32, login string, duration time.Duration) (string, *UserClaims, error)
+	VerifyToken(tokenString string) (*UserClaims, error)
+}
+
+// KeySetPublisher is implemented by makers whose verification keys can be shared with other services.
+type KeySetPublisher interface {
+	JWKS() JWKSet
+}
+
+// NewMaker builds a Maker for the algorithm. HS256 signs with secretKey,
+// RS256 and EdDSA load their keys from keysDir and sign with signingKeyID.
+func NewMaker(algorithm string, secretKey string, keysDir string, signingKeyID string) (Maker, error) {
+	switch algorithm {
+	case "", AlgorithmHS256:
+		return NewJWTMaker(secretKey), nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+		keys, err := LoadKeys(keysDir)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsymmetricMaker(algorithm, signingKeyID, keys)
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", algorithm)
+	}
+}
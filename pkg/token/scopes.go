@@ -0,0 +1,16 @@
+package token
+
+import "slices"
+
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopeUsersWrite = "users:write"
+)
+
+// AllScopes are granted to regular login sessions.
+var AllScopes = []string{ScopePostsRead, ScopePostsWrite, ScopeUsersWrite}
+
+func IsValidScope(scope string) bool {
+	return slices.Contains(AllScopes, scope)
+}